@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wakisa.com/internal/assert"
+	"wakisa.com/internal/models"
+)
+
+// The newTestDB() helper creates a fresh SQLite database, migrated and with
+// the same user as the models tests, and returns the environment which
+// points the admin tool at it.
+func newTestDB(t *testing.T) (*models.SQLiteSnippetModel, map[string]string) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := models.OpenSQLite(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = (&models.Migrator{DB: db, Driver: "sqlite"}).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = (&models.SQLiteUserModel{DB: db}).Insert("Alice Jones", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"SNIPPETBOX_DB_DRIVER": "sqlite", "SNIPPETBOX_DSN": dsn}
+
+	return &models.SQLiteSnippetModel{DB: db}, env
+}
+
+// The runAdmin() helper runs the admin tool with the given environment,
+// standard input and arguments, and returns its exit status and output.
+func runAdmin(t *testing.T, env map[string]string, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr, lookupEnv)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUsers(t *testing.T) {
+	_, env := newTestDB(t)
+
+	// Without -password-stdin, a random password is generated and shown.
+	code, stdout, _ := runAdmin(t, env, "", "-format", "json", "users", "create", "-name", "Bob Smith", "-email", "bob@example.com")
+	assert.Equal(t, code, 0)
+
+	var created struct {
+		ID       int    `json:"id"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := json.Unmarshal([]byte(stdout), &created)
+	assert.NilError(t, err)
+	assert.Equal(t, created.ID, 2)
+	assert.Equal(t, created.Email, "bob@example.com")
+	assert.Equal(t, len(created.Password), 20)
+
+	code, _, stderr := runAdmin(t, env, "", "users", "create", "-name", "Bob Again", "-email", "bob@example.com")
+	assert.Equal(t, code, 1)
+	assert.StringContains(t, stderr, "already exists")
+
+	code, _, stderr = runAdmin(t, env, "short\n", "users", "reset-password", "-password-stdin", "bob@example.com")
+	assert.Equal(t, code, 1)
+	assert.StringContains(t, stderr, "at least 8 characters")
+
+	// A password from standard input isn't echoed back.
+	code, stdout, _ = runAdmin(t, env, "new pa$$word\n", "users", "reset-password", "-password-stdin", "bob@example.com")
+	assert.Equal(t, code, 0)
+	assert.Equal(t, strings.Contains(stdout, "Password:"), false)
+
+	code, stdout, _ = runAdmin(t, env, "", "users", "disable", "bob@example.com")
+	assert.Equal(t, code, 0)
+	assert.StringContains(t, stdout, "disabled")
+
+	code, stdout, _ = runAdmin(t, env, "", "users", "list")
+	assert.Equal(t, code, 0)
+	assert.StringContains(t, stdout, "alice@example.com")
+	assert.StringContains(t, stdout, "bob@example.com")
+
+	var users []models.User
+
+	code, stdout, _ = runAdmin(t, env, "", "-format", "json", "users", "list")
+	assert.Equal(t, code, 0)
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &users))
+	assert.Equal(t, len(users), 2)
+	assert.Equal(t, users[0].Disabled, false)
+	assert.Equal(t, users[1].Disabled, true)
+
+	code, _, stderr = runAdmin(t, env, "", "users", "enable", "carol@example.com")
+	assert.Equal(t, code, 1)
+	assert.StringContains(t, stderr, "no user with the email carol@example.com")
+}
+
+func TestSnippets(t *testing.T) {
+	snippets, env := newTestDB(t)
+
+	params := models.SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: models.VisibilityPublic, Expires: 7}
+
+	for range 3 {
+		_, _, err := snippets.Insert(1, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var listed []models.Snippet
+
+	code, stdout, _ := runAdmin(t, env, "", "-format", "json", "snippets", "list", "-limit", "2")
+	assert.Equal(t, code, 0)
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &listed))
+	assert.Equal(t, len(listed), 2)
+	assert.Equal(t, listed[0].ID, 3)
+
+	code, stdout, _ = runAdmin(t, env, "", "snippets", "extend", "-days", "30", "1")
+	assert.Equal(t, code, 0)
+	assert.StringContains(t, stdout, "EXPIRES")
+
+	// Deleting stops at the first snippet which doesn't exist, but the ones
+	// before it are still deleted.
+	code, stdout, stderr := runAdmin(t, env, "", "-format", "json", "snippets", "delete", "2", "99", "3")
+	assert.Equal(t, code, 1)
+	assert.StringContains(t, stdout, `"deleted": [`)
+	assert.StringContains(t, stderr, "no snippet with the ID 99")
+
+	code, stdout, _ = runAdmin(t, env, "", "snippets", "list", "-user", "alice@example.com")
+	assert.Equal(t, code, 0)
+	assert.Equal(t, strings.Count(stdout, "\n"), 3)
+
+	code, stdout, _ = runAdmin(t, env, "", "purge")
+	assert.Equal(t, code, 0)
+	assert.StringContains(t, stdout, "Purged 0 expired snippets")
+}
+
+func TestUsageErrors(t *testing.T) {
+	_, env := newTestDB(t)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"No command", nil, "no command given"},
+		{"Unknown command", []string{"users", "delete"}, `unknown command "users delete"`},
+		{"Bad format", []string{"-format", "xml", "users", "list"}, "format must be table or json"},
+		{"Missing email", []string{"users", "create", "-name", "Bob"}, "-email must be a valid email address"},
+		{"Bad snippet ID", []string{"snippets", "delete", "abc"}, `"abc" is not a snippet ID`},
+		{"No days", []string{"snippets", "extend", "1"}, "-days must be at least 1"},
+		{"Unknown flag", []string{"purge", "-now"}, "flag provided but not defined: -now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runAdmin(t, env, "", tt.args...)
+			assert.Equal(t, code, 2)
+			assert.StringContains(t, stderr, tt.wantErr)
+		})
+	}
+}
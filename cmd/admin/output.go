@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The output() method writes the result of a command. In json format it
+// encodes v; otherwise it calls text to write something for people to read.
+// Scripts should use json, as the text output may change.
+func (a *admin) output(v any, text func(w io.Writer) error) error {
+	if a.format == "json" {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	return text(a.stdout)
+}
+
+// The writeTable() function writes rows of values as a table with a header,
+// lining up the columns.
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// The formatTime() function formats a time for a table, in UTC.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+// The truncate() function shortens s to at most n characters, for columns
+// like snippet titles which could otherwise make a table unreadable.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"wakisa.com/internal/models"
+)
+
+func (a *admin) snippetsList(args []string) error {
+	fs := newFlagSet("snippets list")
+	email := fs.String("user", "", "")
+	limit := fs.Int("limit", 50, "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || *limit < 1 {
+		return errUsage
+	}
+
+	var snippets []models.Snippet
+	var err error
+
+	if *email != "" {
+		user, err := a.userByEmail(*email)
+		if err != nil {
+			return err
+		}
+
+		snippets, err = a.snippets.ForUser(user.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(snippets) > *limit {
+			snippets = snippets[:*limit]
+		}
+	} else {
+		snippets, err = a.snippets.All(*limit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if snippets == nil {
+		snippets = []models.Snippet{}
+	}
+
+	return a.output(snippets, func(w io.Writer) error {
+		var rows [][]string
+
+		for _, s := range snippets {
+			status := "live"
+			if s.IsExpired() {
+				status = "expired"
+			}
+			rows = append(rows, []string{strconv.Itoa(s.ID), s.Slug, strconv.Itoa(s.UserID), truncate(s.Title, 40), s.Visibility, formatTime(s.Expires), status})
+		}
+
+		return writeTable(w, []string{"ID", "SLUG", "USER", "TITLE", "VISIBILITY", "EXPIRES", "STATUS"}, rows)
+	})
+}
+
+func (a *admin) snippetsDelete(args []string) error {
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+
+	// Delete the snippets one at a time, stopping at the first one which
+	// can't be deleted. The ones before it stay deleted, and the error says
+	// which one it was.
+	deleted := []int{}
+
+	for _, id := range ids {
+		err = a.snippets.Remove(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				err = fmt.Errorf("no snippet with the ID %d", id)
+			}
+			break
+		}
+
+		deleted = append(deleted, id)
+	}
+
+	outErr := a.output(map[string][]int{"deleted": deleted}, func(w io.Writer) error {
+		for _, id := range deleted {
+			fmt.Fprintf(w, "Deleted snippet %d\n", id)
+		}
+		return nil
+	})
+
+	return errors.Join(err, outErr)
+}
+
+func (a *admin) snippetsExtend(args []string) error {
+	fs := newFlagSet("snippets extend")
+	days := fs.Int("days", 0, "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("%w: -days must be at least 1", errUsage)
+	}
+
+	ids, err := parseIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	// Like snippetsDelete(), stop at the first snippet which can't be
+	// extended.
+	type extended struct {
+		ID      int       `json:"id"`
+		Expires time.Time `json:"expires"`
+	}
+
+	done := []extended{}
+
+	for _, id := range ids {
+		err = a.snippets.Extend(id, *days)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				err = fmt.Errorf("no snippet with the ID %d", id)
+			}
+			break
+		}
+
+		var s models.Snippet
+
+		s, err = a.snippets.Get(id)
+		if err != nil {
+			break
+		}
+
+		done = append(done, extended{ID: id, Expires: s.Expires})
+	}
+
+	outErr := a.output(done, func(w io.Writer) error {
+		if len(done) == 0 {
+			return nil
+		}
+
+		var rows [][]string
+		for _, e := range done {
+			rows = append(rows, []string{strconv.Itoa(e.ID), formatTime(e.Expires)})
+		}
+
+		return writeTable(w, []string{"ID", "EXPIRES"}, rows)
+	})
+
+	return errors.Join(err, outErr)
+}
+
+// The purge() method does the same clean-up as the web application's
+// background purge, for when it's been switched off with -purge-interval=0
+// or there's a backlog to clear.
+func (a *admin) purge(args []string) error {
+	fs := newFlagSet("purge")
+	retention := fs.Duration("trash-retention", 30*24*time.Hour, "")
+	batchSize := fs.Int("batch-size", 1000, "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || *retention <= 0 || *batchSize < 1 {
+		return errUsage
+	}
+
+	trashed, err := a.snippets.Purge(*retention)
+	if err != nil {
+		return err
+	}
+
+	// Delete the expired snippets in batches until one comes back short,
+	// which means there's nothing left.
+	var expired int
+
+	for {
+		n, err := a.snippets.PurgeExpired(*batchSize)
+		if err != nil {
+			return err
+		}
+
+		expired += n
+
+		if n < *batchSize {
+			break
+		}
+	}
+
+	result := map[string]int{"trash": trashed, "expired": expired}
+
+	return a.output(result, func(w io.Writer) error {
+		fmt.Fprintf(w, "Purged %d snippets from the trash\n", trashed)
+		fmt.Fprintf(w, "Purged %d expired snippets\n", expired)
+		return nil
+	})
+}
+
+// The parseIDs() function parses the snippet IDs given as arguments. At least
+// one is needed.
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: no snippet IDs given", errUsage)
+	}
+
+	var ids []int
+
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("%w: %q is not a snippet ID", errUsage, arg)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"wakisa.com/internal/models"
+	"wakisa.com/internal/validator"
+)
+
+// Passwords are never given on the command line, where they'd be visible to
+// other users of the machine and end up in the shell's history. Instead a
+// random password is generated and printed, or, with -password-stdin, the
+// password is read from the first line of standard input.
+
+// The minimum password length, the same as the signup form's.
+const minPasswordLength = 8
+
+func (a *admin) usersList(args []string) error {
+	fs := newFlagSet("users list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+
+	users, err := a.users.List()
+	if err != nil {
+		return err
+	}
+
+	// Make sure an empty list comes out as [] rather than null.
+	if users == nil {
+		users = []models.User{}
+	}
+
+	return a.output(users, func(w io.Writer) error {
+		return writeUsers(w, users...)
+	})
+}
+
+// The writeUsers() function writes users as a table.
+func writeUsers(w io.Writer, users ...models.User) error {
+	var rows [][]string
+
+	for _, u := range users {
+		status := "active"
+		if u.Disabled {
+			status = "disabled"
+		}
+		rows = append(rows, []string{strconv.Itoa(u.ID), u.Name, u.Email, formatTime(u.Created), status})
+	}
+
+	return writeTable(w, []string{"ID", "NAME", "EMAIL", "CREATED", "STATUS"}, rows)
+}
+
+// Define a newPassword type for the output of the commands which set a
+// password. Password is only included if it was generated, as that's the
+// only time anybody needs to be told it.
+type newPassword struct {
+	models.User
+	Password string `json:"password,omitempty"`
+}
+
+// The writeNewPassword() function writes the user, followed by the password
+// if there is one.
+func writeNewPassword(w io.Writer, result newPassword) error {
+	err := writeUsers(w, result.User)
+	if err != nil {
+		return err
+	}
+
+	if result.Password != "" {
+		_, err = fmt.Fprintf(w, "\nPassword: %s\n", result.Password)
+	}
+
+	return err
+}
+
+func (a *admin) usersCreate(args []string) error {
+	fs := newFlagSet("users create")
+	name := fs.String("name", "", "")
+	email := fs.String("email", "", "")
+	fromStdin := fs.Bool("password-stdin", false, "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+
+	// Apply the same checks as the signup form.
+	if !validator.NotBlank(*name) {
+		return fmt.Errorf("%w: -name is required", errUsage)
+	}
+	if !validator.Matches(*email, validator.EmailRX) {
+		return fmt.Errorf("%w: -email must be a valid email address", errUsage)
+	}
+
+	password, generated, err := a.password(*fromStdin)
+	if err != nil {
+		return err
+	}
+
+	err = a.users.Insert(*name, *email, password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			return fmt.Errorf("a user with the email %s already exists", *email)
+		}
+		return err
+	}
+
+	user, err := a.users.GetByEmail(*email)
+	if err != nil {
+		return err
+	}
+
+	result := newPassword{User: user}
+	if generated {
+		result.Password = password
+	}
+
+	return a.output(result, func(w io.Writer) error {
+		return writeNewPassword(w, result)
+	})
+}
+
+func (a *admin) usersResetPassword(args []string) error {
+	fs := newFlagSet("users reset-password")
+	fromStdin := fs.Bool("password-stdin", false, "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	user, err := a.userByEmail(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	password, generated, err := a.password(*fromStdin)
+	if err != nil {
+		return err
+	}
+
+	err = a.users.SetPassword(user.ID, password)
+	if err != nil {
+		return err
+	}
+
+	result := newPassword{User: user}
+	if generated {
+		result.Password = password
+	}
+
+	return a.output(result, func(w io.Writer) error {
+		return writeNewPassword(w, result)
+	})
+}
+
+func (a *admin) usersDisable(args []string) error {
+	return a.setDisabled(args, true)
+}
+
+func (a *admin) usersEnable(args []string) error {
+	return a.setDisabled(args, false)
+}
+
+// The setDisabled() method does the work for the disable and enable
+// commands, which differ only in which way they go.
+func (a *admin) setDisabled(args []string, disabled bool) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	user, err := a.userByEmail(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = a.users.SetDisabled(user.ID, disabled)
+	if err != nil {
+		return err
+	}
+
+	user.Disabled = disabled
+
+	return a.output(user, func(w io.Writer) error {
+		return writeUsers(w, user)
+	})
+}
+
+// The userByEmail() method looks up a user, turning ErrNoRecord into an
+// error which says who couldn't be found.
+func (a *admin) userByEmail(email string) (models.User, error) {
+	user, err := a.users.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return models.User{}, fmt.Errorf("no user with the email %s", email)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// The password() method returns the password for a command which sets one:
+// either the first line of standard input, or a new random password. The
+// second return value reports whether the password was generated.
+func (a *admin) password(fromStdin bool) (string, bool, error) {
+	if !fromStdin {
+		password, err := generatePassword()
+		return password, true, err
+	}
+
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+
+	if !validator.MinChars(password, minPasswordLength) {
+		return "", false, fmt.Errorf("the password must be at least %d characters long", minPasswordLength)
+	}
+
+	return password, false, nil
+}
+
+// The generatePassword() function returns a random password made from 15
+// random bytes, which encode to 20 URL-safe base64 characters.
+func generatePassword() (string, error) {
+	b := make([]byte, 15)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
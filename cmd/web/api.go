@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wakisa.com/internal/models"
+)
+
+// Define an envelope type. Every JSON response from the API is wrapped in a
+// top-level object, so a client always gets either a named resource (like
+// "snippet") or an "error" key back, never a bare value.
+type envelope map[string]any
+
+// The apiError() helper sends a JSON error response with the given status
+// code. The message can be a plain string, or a map of field names to
+// messages when a request fails validation.
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, message any) {
+	err := app.writeJSON(w, status, envelope{"error": message}, nil)
+	if err != nil {
+		app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// The apiServerError() helper is the API equivalent of serverError(). It logs
+// the detailed error and sends a generic 500 response in the JSON envelope.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+	app.apiError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiError(w, r, http.StatusNotFound, "the requested resource could not be found")
+}
+
+func (app *application) apiBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	app.apiError(w, r, http.StatusBadRequest, err.Error())
+}
+
+func (app *application) apiFailedValidation(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) {
+	app.apiError(w, r, http.StatusUnprocessableEntity, fieldErrors)
+}
+
+// The apiInvalidCredentials(), apiInvalidToken() and apiAuthenticationRequired()
+// helpers set the WWW-Authenticate header, so that clients know which
+// authentication scheme the API expects.
+func (app *application) apiInvalidCredentials(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="snippetbox"`)
+	app.apiError(w, r, http.StatusUnauthorized, "invalid authentication credentials")
+}
+
+func (app *application) apiInvalidToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.apiError(w, r, http.StatusUnauthorized, "invalid or expired authentication token")
+}
+
+func (app *application) apiAuthenticationRequired(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("WWW-Authenticate", "Bearer")
+	w.Header().Add("WWW-Authenticate", `Basic realm="snippetbox"`)
+	app.apiError(w, r, http.StatusUnauthorized, "you must be authenticated to access this resource")
+}
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	cursor, err := app.readCursor(r)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+
+	page, err := app.snippets.Latest(cursor)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// Make sure an empty result is sent as [] rather than null.
+	snippets := page.Snippets
+	if snippets == nil {
+		snippets = []models.Snippet{}
+	}
+
+	// Password protected snippets are listed, but their content is left out
+	// for anyone but their creator.
+	userID := app.authenticatedUserID(r)
+	for i := range snippets {
+		if snippets[i].IsProtected() && snippets[i].UserID != userID {
+			snippets[i].Content = ""
+		}
+	}
+
+	// Send the cursors for the neighbouring pages alongside the snippets, so
+	// that clients can follow them with the before and after parameters. As
+	// in the Page struct, 0 means there's no page in that direction.
+	data := envelope{
+		"snippets": snippets,
+		"metadata": envelope{"limit": page.Limit, "next": page.Next, "prev": page.Prev},
+	}
+
+	err = app.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("slug")
+
+	// Old numeric IDs are redirected to the slug-based URL, but only for
+	// public snippets, just like in the HTML interface.
+	if id, err := strconv.Atoi(key); err == nil {
+		snippet, err := app.snippets.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.apiNotFound(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+
+		if snippet.Visibility != models.VisibilityPublic {
+			app.apiNotFound(w, r)
+			return
+		}
+
+		http.Redirect(w, r, "/api/v1/snippets/"+snippet.Slug, http.StatusMovedPermanently)
+		return
+	}
+
+	snippet, err := app.snippets.GetBySlug(key)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+
+		return
+	}
+
+	// As in the HTML interface, a private snippet looks like it doesn't exist
+	// to anyone but its creator.
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		app.apiNotFound(w, r)
+		return
+	}
+
+	// API clients don't have a session to unlock a password protected snippet
+	// in, so only its creator can fetch it.
+	if snippet.IsProtected() && snippet.UserID != app.authenticatedUserID(r) {
+		app.apiError(w, r, http.StatusForbidden, "this snippet is password protected")
+		return
+	}
+
+	// There's no confirmation step in the API, so fetching a view-limited
+	// snippet uses up one of its views straight away.
+	if snippet.IsViewLimited() {
+		snippet, err = app.snippets.Consume(snippet.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.apiNotFound(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil)
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	// Decode the request body into the same snippetCreateForm struct that the
+	// HTML form uses, so that both go through exactly the same validation.
+	// Clients which don't send a language get plain text, and snippets are
+	// public unless the client says otherwise.
+	form := snippetCreateForm{
+		Language:   "plaintext",
+		Visibility: models.VisibilityPublic,
+	}
+
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		app.apiFailedValidation(w, r, form.FieldErrors)
+		return
+	}
+
+	id, slug, err := app.snippets.Insert(app.authenticatedUserID(r), form.params())
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSlug) {
+			form.AddFieldError("slug", "This slug is already in use")
+			app.apiFailedValidation(w, r, form.FieldErrors)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	// Let the client know where the new snippet lives.
+	headers := make(http.Header)
+	headers.Set("Location", "/api/v1/snippets/"+slug)
+
+	// Version 1 of the API has always returned the new snippet's ID, so it
+	// still does, alongside the slug.
+	err = app.writeJSON(w, http.StatusCreated, envelope{"id": id, "slug": slug}, headers)
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
@@ -3,3 +3,11 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// The authenticatedUserIDContextKey holds the ID of the user making the
+// request, however they authenticated (session, Basic Auth or bearer token).
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// The tokenContextKey holds the models.Token used to authenticate an API
+// request, when the request was authenticated with a bearer token.
+const tokenContextKey = contextKey("token")
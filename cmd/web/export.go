@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"mime"
+	"regexp"
+	"strings"
+
+	"wakisa.com/internal/models"
+)
+
+// A snippet can be fetched in several formats besides its page: as raw text,
+// as a file download, and exported as a standalone HTML page, a Markdown
+// document or JSON. The helpers for building them live here; the handlers
+// are in handlers.go.
+
+// The formats a snippet can be exported in, by the name used in the URL, with
+// the Content-Type and file extension for each.
+var exportFormats = map[string]struct {
+	contentType string
+	ext         string
+}{
+	"html":     {"text/html; charset=utf-8", ".html"},
+	"markdown": {"text/markdown; charset=utf-8", ".md"},
+	"json":     {"application/json", ".json"},
+}
+
+// Match the runs of characters which aren't allowed in a filename built from
+// a snippet's title.
+var filenameRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// The snippetFilename() function returns the filename for a snippet saved
+// with the given extension. It's built from the title, lower-cased with
+// anything other than ASCII letters and digits replaced by hyphens, so it's
+// safe on every operating system. A title with nothing usable in it falls
+// back to the snippet's slug.
+func snippetFilename(s models.Snippet, ext string) string {
+	name := strings.Trim(filenameRX.ReplaceAllString(strings.ToLower(s.Title), "-"), "-")
+
+	if len(name) > 60 {
+		name = strings.TrimRight(name[:60], "-")
+	}
+
+	if name == "" {
+		name = "snippet-" + s.Slug
+	}
+
+	return name + ext
+}
+
+// The attachment() function returns the value for a Content-Disposition
+// header which tells the browser to save the response as a file with the
+// given name.
+func attachment(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+// The exportMarkdown() function returns a snippet as a Markdown document: the
+// title as a heading, followed by the content in a fenced code block. A
+// Markdown snippet is already Markdown, so its content goes in as it is.
+func exportMarkdown(s models.Snippet) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# %s\n\n", s.Title)
+
+	if s.Language == "markdown" {
+		buf.WriteString(strings.TrimRight(s.Content, "\n"))
+		buf.WriteString("\n")
+	} else {
+		// The fence has to be longer than any run of backticks in the
+		// content, or the content could close it early.
+		fence := strings.Repeat("`", max(3, longestRun(s.Content, '`')+1))
+
+		lang := s.Language
+		if lang == "plaintext" {
+			lang = ""
+		}
+
+		fmt.Fprintf(&buf, "%s%s\n%s\n%s\n", fence, lang, strings.TrimRight(s.Content, "\n"), fence)
+	}
+
+	if len(s.Tags) > 0 {
+		fmt.Fprintf(&buf, "\nTags: %s\n", strings.Join(s.Tags, ", "))
+	}
+
+	return buf.Bytes()
+}
+
+// The longestRun() function returns the length of the longest run of the
+// byte c in s.
+func longestRun(s string, c byte) int {
+	var longest, run int
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	return longest
+}
+
+// The highlightStyles() template function returns the stylesheet for
+// highlighted snippets, so that the standalone HTML export can include it
+// inline rather than linking to /static/css/highlight.css.
+func highlightStyles() (template.CSS, error) {
+	css, err := highlightCSS()
+	if err != nil {
+		return "", err
+	}
+
+	return template.CSS(css), nil
+}
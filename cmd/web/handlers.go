@@ -1,88 +1,677 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"html/template"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"wakisa.com/internal/diff"
 	"wakisa.com/internal/models"
 	"wakisa.com/internal/validator"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
+	cursor, err := app.readCursor(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Latest(cursor)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	tagCounts, err := app.snippets.TagCounts(20)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = page.Snippets
+	data.Page = page
+	data.TagCounts = tagCounts
+
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
+}
+
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	// A name which could never be a valid tag can't have any snippets, so
+	// there's no need to go to the database.
+	tag := r.PathValue("name")
+	if !validator.Matches(tag, validator.TagRX) {
+		http.NotFound(w, r)
+		return
+	}
+
+	cursor, err := app.readCursor(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.ForTag(tag, cursor)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets = page.Snippets
+	data.Page = page
+
+	app.render(w, r, http.StatusOK, "tag.tmpl", data)
+}
+
+// Define a searchForm struct to hold the search query. Unlike our other
+// forms it's submitted with GET, so that searches can be bookmarked and
+// paginated.
+type searchForm struct {
+	Query               string
+	validator.Validator `form:"-"`
+}
+
+func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	form := searchForm{
+		Query: strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	// With no query we just show the search box.
+	if form.Query == "" {
+		app.render(w, r, http.StatusOK, "search.tmpl", data)
+		return
+	}
+
+	form.CheckField(validator.MaxChars(form.Query, 100), "q", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "search.tmpl", data)
+		return
+	}
+
+	cursor, err := app.readCursor(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Search(form.Query, cursor)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Snippets = page.Snippets
+	data.Page = page
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	// Viewing a view-limited snippet uses up one of its views, so rather than
+	// showing it straight away we ask the reader to confirm first. This also
+	// stops the creator from burning it on the redirect after creating it,
+	// and link previews from burning it when the link is shared.
+	if snippet.IsViewLimited() {
+		app.render(w, r, http.StatusOK, "reveal.tmpl", data)
+		return
+	}
+
+	err := app.addForkData(r, &data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+func (app *application) snippetRevealPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !snippet.IsViewLimited() {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	// The Consume() method uses up a view atomically. If somebody else used
+	// the last one since we fetched the snippet above, it's gone.
+	snippet, err := app.snippets.Consume(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	switch snippet.ViewsLeft {
+	case 1:
+		data.Flash = "This snippet has now been destroyed. Make sure you've copied anything you need before leaving this page."
+	case 2:
+		data.Flash = "This snippet can be viewed once more before it's destroyed."
+	default:
+		data.Flash = fmt.Sprintf("This snippet can be viewed %d more times before it's destroyed.", snippet.ViewsLeft-1)
+	}
+
+	err = app.addForkData(r, &data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Make sure the browser doesn't keep a copy of the snippet which could be
+	// read again after it's been destroyed.
+	w.Header().Set("Cache-Control", "no-store")
+
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// The addForkData() helper adds the snippet's parent and forks to the data for
+// its page. The parent is left out if it has expired or been deleted, and only
+// the forks which would appear in the public listings, or which belong to the
+// current user, are included.
+func (app *application) addForkData(r *http.Request, data *templateData) error {
+	if data.Snippet.ParentID != 0 {
+		parent, err := app.snippets.Get(data.Snippet.ParentID)
+		if err == nil {
+			data.Parent = &parent
+		} else if !errors.Is(err, models.ErrNoRecord) {
+			return err
+		}
+	}
+
+	forks, err := app.snippets.Forks(data.Snippet.ID)
+	if err != nil {
+		return err
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	for _, fork := range forks {
+		listed := fork.Visibility == models.VisibilityPublic && !fork.IsViewLimited()
+		if listed || (userID != 0 && fork.UserID == userID) {
+			data.Forks = append(data.Forks, fork)
+		}
+	}
+
+	return nil
+}
+
+// The snippetFromPath() helper fetches the snippet identified by the {slug}
+// path value. If no matching snippet is found, or it's private and belongs to
+// somebody else, it sends a 404 Not Found response and returns false, so the
+// caller just needs to return. If the snippet is password protected and
+// hasn't been unlocked, it shows the unlock form instead.
+func (app *application) snippetFromPath(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if !app.isUnlocked(r, snippet) {
+		app.renderUnlock(w, r, http.StatusForbidden, snippet, snippetUnlockForm{})
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// The findSnippet() helper does the work of snippetFromPath(), apart from
+// checking whether a protected snippet has been unlocked.
+//
+// Snippet URLs used to contain the numeric ID instead of the slug. To keep
+// old links working, a GET request for a numeric ID is redirected to the same
+// URL with the slug in its place -- but only for public snippets, because
+// following sequential IDs mustn't be a way to find unlisted ones.
+func (app *application) findSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	key := r.PathValue("slug")
+
+	if id, err := strconv.Atoi(key); err == nil {
+		app.redirectLegacySnippet(w, r, key, id)
+		return models.Snippet{}, false
+	}
+
+	// Use the SnippetMOdel's GetBySlug() method to retrieve the data for a
+	// specific record based on its slug. If no matching record is found,
+	// return a 404 Not Found response.
+	snippet, err := app.snippets.GetBySlug(key)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return models.Snippet{}, false
+	}
+
+	// Private snippets are only visible to their creator. Everyone else gets
+	// the same 404 as for a snippet which doesn't exist, so that we don't give
+	// away that there's something there.
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// The redirectLegacySnippet() helper sends a permanent redirect from an old
+// numeric snippet URL to its slug-based replacement, or a 404 if there's no
+// such public snippet.
+func (app *application) redirectLegacySnippet(w http.ResponseWriter, r *http.Request, key string, id int) {
+	if r.Method != http.MethodGet || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Visibility != models.VisibilityPublic {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Swap the ID for the slug, keeping the rest of the path (such as
+	// /history) and the query string as they were.
+	u := *r.URL
+	u.Path = strings.Replace(u.Path, "/"+key, "/"+snippet.Slug, 1)
+
+	http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+}
+
+// The isUnlocked() helper returns true if the current user can see the
+// content of a snippet: either it isn't password protected, they created it,
+// or they've entered its password during this session.
+func (app *application) isUnlocked(r *http.Request, snippet models.Snippet) bool {
+	if !snippet.IsProtected() {
+		return true
+	}
+
+	if userID := app.authenticatedUserID(r); userID != 0 && userID == snippet.UserID {
+		return true
+	}
+
+	return app.sessionManager.GetBool(r.Context(), unlockSessionKey(snippet))
+}
+
+// The unlockSessionKey() function returns the session key which records that
+// a protected snippet has been unlocked. Each snippet gets its own key, so
+// unlocking one snippet doesn't unlock any others.
+func unlockSessionKey(snippet models.Snippet) string {
+	return fmt.Sprintf("unlockedSnippet:%d", snippet.ID)
+}
+
+// Define a snippetUnlockForm struct to hold the password entered for a
+// protected snippet.
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// The renderUnlock() helper shows the form for entering a protected snippet's
+// password. Only the snippet's title is shown, never its content.
+func (app *application) renderUnlock(w http.ResponseWriter, r *http.Request, status int, snippet models.Snippet, form snippetUnlockForm) {
+	data := app.newTemplateData(r)
+	data.Snippet = models.Snippet{ID: snippet.ID, Slug: snippet.Slug, Title: snippet.Title}
+	data.Form = form
+
+	app.render(w, r, status, "unlock.tmpl", data)
+}
+
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if app.isUnlocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Failed attempts are counted per client and per snippet, so that nobody
+	// can sit and guess a snippet's password.
+	key := fmt.Sprintf("%s:%d", clientIP(r), snippet.ID)
+
+	if !app.unlockLimiter.allow(key) {
+		form.AddNonFieldError("Too many incorrect passwords. Please try again later.")
+		app.renderUnlock(w, r, http.StatusTooManyRequests, snippet, form)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.renderUnlock(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	err = app.snippets.VerifyPassword(snippet.ID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.unlockLimiter.fail(key)
+
+			form.AddNonFieldError("The password is incorrect")
+			app.renderUnlock(w, r, http.StatusUnprocessableEntity, snippet, form)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.unlockLimiter.reset(key)
+
+	// Remember the unlock for the rest of the session, for this snippet only.
+	app.sessionManager.Put(r.Context(), unlockSessionKey(snippet), true)
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// The history of a view-limited snippet would give its content away
+	// without using up a view, so only its creator can see it.
+	if snippet.IsViewLimited() && snippet.UserID != app.authenticatedUserID(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// The history of a view-limited snippet would give its content away
+	// without using up a view, so only its creator can see it.
+	if snippet.IsViewLimited() && snippet.UserID != app.authenticatedUserID(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if len(revisions) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Either version can be chosen in the query string, so that any two
+	// revisions can be compared. By default we show the changes made in the
+	// latest revision (the revisions are returned newest first), and if only
+	// "to" is given we compare it with the revision before it.
+	qs := r.URL.Query()
+
+	to := revisions[0].Version
+	if qs.Has("to") {
+		to, err = strconv.Atoi(qs.Get("to"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	from := max(to-1, 1)
+	if qs.Has("from") {
+		from, err = strconv.Atoi(qs.Get("from"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromRevision, err := app.snippets.Revision(snippet.ID, from)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	toRevision, err := app.snippets.Revision(snippet.ID, to)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	data.FromRevision = fromRevision
+	data.ToRevision = toRevision
+	data.Diff = diff.Lines(fromRevision.Content, toRevision.Content)
+
+	app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
+// The exportableSnippet() helper fetches the snippet for the raw, download
+// and export handlers, applying the same rules as the snippet's page: expired
+// and trashed snippets aren't found, private ones are only visible to their
+// creator, and protected ones have to be unlocked first. These formats have
+// no step for confirming that a view should be used up, so, like the history,
+// a view-limited snippet is only available to its creator.
+func (app *application) exportableSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if snippet.IsViewLimited() && snippet.UserID != app.authenticatedUserID(r) {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	// A file saved from one of these responses can easily outlive the
+	// session which was allowed to see it, so make sure nothing but a public
+	// snippet is kept in a cache along the way.
+	if snippet.Visibility != models.VisibilityPublic || snippet.IsProtected() || snippet.IsViewLimited() {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	return snippet, true
+}
+
+// The snippetRaw() handler sends just the content of a snippet as plain text,
+// for piping into other tools. The nosniff header is set by commonHeaders()
+// too, but it's set again here because it's what stops a browser treating the
+// content as HTML, and this handler mustn't depend on it being there.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.exportableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(snippet.Content))
+}
+
+// The snippetDownload() handler sends the same response as snippetRaw(), but
+// tells the browser to save it as a file named after the snippet's title,
+// with the usual extension for its language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.exportableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", attachment(snippetFilename(snippet, languageExt(snippet.Language))))
+	w.Write([]byte(snippet.Content))
+}
+
+// The snippetExport() handler sends a snippet as a file in one of the
+// formats in exportFormats: a standalone HTML page, a Markdown document, or
+// the same JSON as the API returns.
+func (app *application) snippetExport(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormats[r.PathValue("format")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
 
-	snippets, err := app.snippets.Latest()
-	if err != nil {
-		app.serverError(w, r, err)
+	snippet, ok := app.exportableSnippet(w, r)
+	if !ok {
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	filename := snippetFilename(snippet, format.ext)
 
-	app.render(w, r, http.StatusOK, "home.tmpl", data)
-}
+	// The JSON export is the same as the API's response for the snippet, so
+	// it's written by the same helper.
+	if r.PathValue("format") == "json" {
+		headers := http.Header{"Content-Disposition": {attachment(filename)}}
 
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil || id < 1 {
-		http.NotFound(w, r)
+		err := app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet}, headers)
+		if err != nil {
+			app.serverError(w, r, err)
+		}
 		return
 	}
 
-	// Use the SnippetMOdel's Get() method to retrieve the data for a
-	// specific record based on its ID. If no matching record is found,
-	// return a 404 Not Found response.
-	snippet, err := app.snippets.Get(id)
-	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			http.NotFound(w, r)
-		} else {
+	var body []byte
+
+	if r.PathValue("format") == "html" {
+		buf := new(bytes.Buffer)
+
+		err := app.templateCache["export.tmpl"].ExecuteTemplate(buf, "export", templateData{Snippet: snippet})
+		if err != nil {
 			app.serverError(w, r, err)
+			return
 		}
 
-		return
+		body = buf.Bytes()
+	} else {
+		body = exportMarkdown(snippet)
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", attachment(filename))
+	w.Write(body)
+}
 
-	app.render(w, r, http.StatusOK, "view.tmpl", data)
+// The canFork() helper reports whether the current user is allowed to fork a
+// snippet. Forking copies the snippet's content, so it needs the same access
+// as reading it without using up a view: the snippet has to be visible to
+// them and unlocked, and a view-limited snippet can only be forked by its
+// creator.
+func (app *application) canFork(r *http.Request, snippet models.Snippet) bool {
+	userID := app.authenticatedUserID(r)
 
-	// Initialize a slice containing the paths to the view.tmpl file,
-	// plus the base layout and navigation partial that we made earlier.
-	files := []string{
-		"./ui/html/base.tmpl",
-		"./ui/html/partials/nav.tmpl",
-		"./ui/html/pages/view.tmpl",
+	if !snippet.VisibleTo(userID) || !app.isUnlocked(r, snippet) {
+		return false
 	}
 
-	// Parse the template files...
-	ts, err := template.ParseFiles(files...)
-	if err != nil {
-		app.serverError(w, r, err)
+	return !snippet.IsViewLimited() || snippet.UserID == userID
+}
+
+// The snippetFork() handler shows the create snippet form filled in with a
+// copy of an existing snippet, which the user can change before publishing
+// it as a new snippet of their own. The form carries the ID of the original,
+// so that the new snippet records where it was forked from. The password and
+// view limit aren't copied.
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	// Pass in the templateData struct when executing the template.
-	err = ts.ExecuteTemplate(w, "base", data)
-	if err != nil {
-		app.serverError(w, r, err)
+	if !app.canFork(r, snippet) {
+		http.NotFound(w, r)
+		return
 	}
 
-	// And then execute them. Notice how we are passing in the snippet
-	// data (a models.Snippet struct) as the final parameter?
-	err = ts.ExecuteTemplate(w, "base", snippet)
-	if err != nil {
-		app.serverError(w, r, err)
+	language := snippet.Language
+	if language == "" {
+		language = "plaintext"
 	}
 
-	// Write the snippet data as a plain-text HTTP response body.
-
-	fmt.Fprintf(w, "%+v", snippet)
+	data := app.newTemplateData(r)
+	data.Form = snippetCreateForm{
+		ParentID:   snippet.ID,
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   language,
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: snippet.Visibility,
+		Expires:    365,
+	}
 
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
@@ -93,7 +682,9 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// 'initial' values for the form --- here we set the initial value fo the
 	// snippet expiry to 365 days.
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Language:   "plaintext",
+		Visibility: models.VisibilityPublic,
+		Expires:    365,
 	}
 
 	app.render(w, r, http.StatusOK, "create.tmpl", data)
@@ -118,10 +709,24 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.validate()
+
+	// A fork is checked again here, as the original may have gone since the
+	// form was shown, and the parent_id field could have been changed to a
+	// snippet the user isn't allowed to see. Either way the link to the
+	// original is dropped, so publishing again saves a plain new snippet.
+	if form.ParentID != 0 {
+		parent, err := app.snippets.Get(form.ParentID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if err != nil || !app.canFork(r, parent) {
+			form.ParentID = 0
+			form.AddNonFieldError("The snippet you were forking is no longer available. Publish again to save this as a new snippet.")
+		}
+	}
 
 	// If there are any errors, dump them in a plain text HTTP response and
 	// return from the handler.
@@ -134,10 +739,19 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	}
 
 	// Pass the data to the SnippetModel.Insert() method, receiving the
-	// ID of the new record back.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// slug of the new record back. If the user asked for a vanity slug which
+	// is already taken, we show them the form again with an error.
+	_, slug, err := app.snippets.Insert(app.authenticatedUserID(r), form.params())
 	if err != nil {
-		app.serverError(w, r, err)
+		if errors.Is(err, models.ErrDuplicateSlug) {
+			form.AddFieldError("slug", "This slug is already in use")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
 		return
 	}
 
@@ -146,21 +760,266 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
 	// Redirect the user to the relevant page for the snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", slug), http.StatusSeeOther)
 	//w.WriteHeader(http.StatusCreated)
 	//w.Write([]byte("Save a new snippet..."))
 }
 
+// The ownedSnippet() helper fetches the snippet identified by the {id}
+// path value and checks that the current user owns it, sending a 404 or 403
+// response (and returning false) if not. It's shared by the handlers which
+// change a snippet.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	// Only the user who created a snippet is allowed to change it.
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Pre-populate the form with the current snippet. The expiry is chosen
+	// afresh on every edit, so we default to whichever option is closest to
+	// the time the snippet has left.
+	expires := 365
+	switch remaining := time.Until(snippet.Expires); {
+	case remaining <= 24*time.Hour:
+		expires = 1
+	case remaining <= 7*24*time.Hour:
+		expires = 7
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: snippet.Visibility,
+		Expires:    expires,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	// The snippet could have expired or been deleted since ownedSnippet()
+	// fetched it, in which case there's nothing left to update.
+	err = app.snippets.Update(snippet.ID, form.params())
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	// Expired snippets are still listed on the My snippets page until they're
+	// purged, so their owner has to be able to move them to the trash too.
+	// ownedSnippet() can't find them, so we look for one of the user's own
+	// snippets first, and only fall back to ownedSnippet() for the 404 or 403
+	// response when that fails.
+	snippet, err := app.snippets.GetOwned(app.authenticatedUserID(r), r.PathValue("slug"))
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		var ok bool
+
+		snippet, ok = app.ownedSnippet(w, r)
+		if !ok {
+			return
+		}
+	}
+
+	// Deleting only moves the snippet to the trash, so it can still be
+	// restored until the retention window runs out.
+	err = app.snippets.Delete(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet moved to the trash.")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
 // Define a snippetCreateForm struct to represent the form data and validation
 // errors for the form fields. Note that all the struct fields are deliberately
 // exported (i.e start with a capital letter). This is because struct fields
 // must be exported in order to be read by the html/template package when
 // rendering the template.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
-	validator.Validator `form:"-"`
+	Slug                string `form:"slug" json:"slug"`
+	Password            string `form:"password" json:"password"`
+	MaxViews            int    `form:"max_views" json:"max_views"`
+	ParentID            int    `form:"parent_id" json:"-"`
+	Title               string `form:"title" json:"title"`
+	Content             string `form:"content" json:"content"`
+	Language            string `form:"language" json:"language"`
+	Tags                string `form:"tags" json:"tags"`
+	Visibility          string `form:"visibility" json:"visibility"`
+	Expires             int    `form:"expires" json:"expires"`
+	validator.Validator `form:"-" json:"-"`
+}
+
+// The maximum number of tags a snippet can have.
+const maxTags = 5
+
+// The highest view limit a snippet can be given.
+const maxViews = 100
+
+// The parseTags() function splits a comma-separated list of tags, as typed
+// into the snippet form, into a slice. Tags are lowercased and trimmed, and
+// empty entries and duplicates are dropped.
+func parseTags(s string) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// The validate() method runs the checks for a new snippet and records any
+// failures in the embedded Validator. Keeping them here means the HTML form
+// and the JSON API can't drift apart in what they accept.
+func (form *snippetCreateForm) validate() {
+	if form.Slug != "" {
+		form.CheckField(validator.Matches(form.Slug, models.SlugRX), "slug", "This field must be 3 to 32 lowercase letters, digits or hyphens, starting with a letter")
+	}
+	form.CheckField(form.MaxViews >= 0 && form.MaxViews <= maxViews, "max_views", fmt.Sprintf("This field must be between 0 and %d", maxViews))
+	if form.Password != "" {
+		form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		// bcrypt only looks at the first 72 bytes of a password.
+		form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+	}
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Language, languageValues()...), "language", "This field must be one of the listed languages")
+	form.CheckField(validator.MaxItems(parseTags(form.Tags), maxTags), "tags", fmt.Sprintf("This field cannot have more than %d tags", maxTags))
+	form.CheckField(validator.AllMatch(parseTags(form.Tags), validator.TagRX), "tags", "Tags can only contain letters, digits and + . _ - and be at most 32 characters long")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must be public, unlisted or private")
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+}
+
+// The params() method converts a validated form into the models.SnippetParams
+// expected by SnippetModel.Insert() and Update().
+func (form *snippetCreateForm) params() models.SnippetParams {
+	return models.SnippetParams{
+		Slug:       form.Slug,
+		Password:   form.Password,
+		MaxViews:   form.MaxViews,
+		ParentID:   form.ParentID,
+		Title:      form.Title,
+		Content:    form.Content,
+		Language:   form.Language,
+		Tags:       parseTags(form.Tags),
+		Visibility: form.Visibility,
+		Expires:    form.Expires,
+	}
+}
+
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "user_snippets.tmpl", data)
+}
+
+func (app *application) userTrash(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Trash(app.authenticatedUserID(r), app.trashRetention)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.TrashRetentionDays = int(app.trashRetention.Hours() / 24)
+
+	app.render(w, r, http.StatusOK, "trash.tmpl", data)
+}
+
+func (app *application) userTrashRestorePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Restore() only matches the current user's snippets, so trying to
+	// restore anybody else's gets a 404.
+	err = app.snippets.Restore(app.authenticatedUserID(r), id, app.trashRetention)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet restored.")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
 }
 
 // Create a new userSignupform struct.
@@ -268,19 +1127,24 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check whether the credentials are valid. If they're not, add a generic
-	// non-field error message and re-display the login page.
+	// non-field error message and re-display the login page. A disabled
+	// account gets its own message, but Authenticate() only reports that once
+	// the password has been checked, so it gives nothing away to a stranger.
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
 			form.AddNonFieldError("Email or password is incorrect")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
-		} else {
+		case errors.Is(err, models.ErrAccountDisabled):
+			form.AddNonFieldError("Your account has been disabled")
+		default:
 			app.serverError(w, r, err)
+			return
 		}
 
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
 
@@ -323,6 +1187,123 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// Create a new tokenCreateForm struct for minting personal access tokens.
+// The Scopes field is a slice because the form has a checkbox per scope.
+type tokenCreateForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	Expires             int      `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	form := tokenCreateForm{
+		Scopes:  []string{models.ScopeSnippetsRead},
+		Expires: 30,
+	}
+
+	app.renderAccount(w, r, http.StatusOK, form)
+}
+
+// The renderAccount() helper renders the account page, which shows the
+// user's details and personal access tokens along with the form for creating
+// a new token. It's shared by accountView() and by accountTokenCreatePost()
+// when it needs to re-display the form with validation errors.
+func (app *application) renderAccount(w http.ResponseWriter, r *http.Request, status int, form tokenCreateForm) {
+	userID := app.authenticatedUserID(r)
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.tokens.ForUser(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Tokens = tokens
+	data.Scopes = models.Scopes
+	// A newly created token is only ever shown once, so pop it from the
+	// session rather than just reading it.
+	data.NewToken = app.sessionManager.PopString(r.Context(), "newToken")
+	data.Form = form
+
+	app.render(w, r, status, "account.tmpl", data)
+}
+
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "You must choose at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.Scopes...), "scopes", "This field contains an unknown scope")
+	}
+	form.CheckField(validator.PermittedValue(form.Expires, 0, 7, 30, 90, 365), "expires", "This field must equal 0, 7, 30, 90 or 365")
+
+	if !form.Valid() {
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	token, err := app.tokens.Insert(userID, form.Name, form.Scopes, form.Expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Stash the plain-text token in the session so that the account page can
+	// show it to the user once after the redirect.
+	app.sessionManager.Put(r.Context(), "newToken", token)
+	app.sessionManager.Put(r.Context(), "flash", "Token created. Make sure to copy it now, you won't be able to see it again!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// Revoke() only matches tokens which belong to the current user, so a
+	// user trying to revoke somebody else's token just gets a 404.
+	err = app.tokens.Revoke(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
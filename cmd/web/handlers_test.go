@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"wakisa.com/internal/assert"
@@ -20,6 +21,169 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, body, "OK")
 }
 
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First page",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Popular tags",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "<a class='tag' href='/tag/haiku'>haiku <small>1</small></a>",
+		},
+		{
+			name:     "Older page",
+			urlPath:  "/?before=2&limit=5",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/?after=1&limit=5'>",
+		},
+		{
+			name:     "Invalid cursor",
+			urlPath:  "/?before=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Zero limit",
+			urlPath:  "/?limit=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Both directions",
+			urlPath:  "/?before=2&after=1",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTagView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Tag with snippets",
+			urlPath:  "/tag/haiku",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/silentpond'>An old silent pond</a>",
+		},
+		{
+			name:     "Tag without snippets",
+			urlPath:  "/tag/golang",
+			wantCode: http.StatusOK,
+			wantBody: "There are no snippets with this tag.",
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/tag/Not%20A%20Tag",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags(" Go, http,, go ,HTTP, sql ")
+
+	assert.Equal(t, strings.Join(got, ","), "go,http,sql")
+}
+
+func TestSnippetSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "No query",
+			urlPath:  "/snippet/search",
+			wantCode: http.StatusOK,
+			wantBody: "<input type='text' name='q' value=''>",
+		},
+		{
+			name:     "Match",
+			urlPath:  "/snippet/search?q=pond",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent <mark>pond</mark>",
+		},
+		{
+			name:     "No match",
+			urlPath:  "/snippet/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets matched your search.",
+		},
+		{
+			name:     "Long query",
+			urlPath:  "/snippet/search?q=" + strings.Repeat("a", 101),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be more than 100 characters long",
+		},
+		{
+			name:     "Invalid cursor",
+			urlPath:  "/snippet/search?q=pond&before=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
 func TestSnippetView(t *testing.T) {
 	// Create a new instance of our application struct which uses the mocked
 	// dependencies.
@@ -39,10 +203,32 @@ func TestSnippetView(t *testing.T) {
 	}{
 		{
 			name:     "valid ID",
-			urlPath:  "/snippet/view/1",
+			urlPath:  "/snippet/view/silentpond",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Markdown",
+			urlPath:  "/snippet/view/runbook",
+			wantCode: http.StatusOK,
+			wantBody: "<h1>Runbook</h1>",
+		},
+		{
+			name:     "Unlisted",
+			urlPath:  "/snippet/view/stagehosts",
+			wantCode: http.StatusOK,
+			wantBody: "staging.example.com",
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/view/dbpassword",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private history",
+			urlPath:  "/snippet/view/dbpassword/history",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -83,120 +269,1208 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
-func TestUserSignup(t *testing.T) {
-	// Create the application struct containg our mocked dependencies and set
-	// up the test seerver for running an end-to-end test.
+func TestSnippetViewLegacyID(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	// Make a GET /user/signup request and then extract the CSRF token from the
-	// response body.
-	_, _, body := ts.get(t, "/user/signup")
-	validCSRFToken := extractCSRFToken(t, body)
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Public snippet",
+			urlPath:      "/snippet/view/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/silentpond",
+		},
+		{
+			name:         "Sub-page and query string",
+			urlPath:      "/snippet/view/1/diff?to=2",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/silentpond/diff?to=2",
+		},
+		{
+			name:     "Unlisted snippet",
+			urlPath:  "/snippet/view/8",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private snippet",
+			urlPath:  "/snippet/view/7",
+			wantCode: http.StatusNotFound,
+		},
+	}
 
-	const (
-		validName     = "Bob"
-		validPassword = "validPa$$word"
-		validEmail    = "bob@example.com"
-		formTag       = "<form action='/user/signup' method='POST' novalidate>"
-	)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
 
 	tests := []struct {
 		name         string
-		userName     string
-		userEmail    string
-		userPassword string
-		csrfToken    string
+		slug         string
+		password     string
+		maxViews     string
 		wantCode     int
-		wantFormTag  string
+		wantLocation string
+		wantBody     string
 	}{
 		{
-			name:         "Valid submission",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
+			name:         "Random slug",
+			slug:         "",
 			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newsnippet",
 		},
 		{
-			name:         "Invalid CSRF Token",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    "wrongToken",
-			wantCode:     http.StatusBadRequest,
+			name:         "Vanity slug",
+			slug:         "frog-haiku",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/frog-haiku",
 		},
 		{
-			name:         "Empty name",
-			userName:     "",
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Slug in use",
+			slug:     "silentpond",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This slug is already in use",
 		},
 		{
-			name:         "Empty email",
-			userName:     validName,
-			userEmail:    "",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Numeric slug",
+			slug:     "12345",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be 3 to 32 lowercase letters, digits or hyphens, starting with a letter",
 		},
 		{
-			name:         "Empty password",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: "",
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Invalid characters",
+			slug:     "Frog Haiku",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be 3 to 32 lowercase letters, digits or hyphens, starting with a letter",
 		},
 		{
-			name:         "Invalid email",
-			userName:     validName,
-			userEmail:    "bob@example.",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:         "Password",
+			password:     "open sesame",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newsnippet",
 		},
 		{
-			name:         "Short passwprd",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: "pa$$",
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:         "View limit",
+			slug:         "secret-note",
+			maxViews:     "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/secret-note",
 		},
 		{
-			name:         "Duplicate email",
-			userName:     validName,
-			userEmail:    "dupe@example.com",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Negative view limit",
+			maxViews: "-1",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be between 0 and 100",
+		},
+		{
+			name:     "Short password",
+			password: "sesame",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be at least 8 characters long",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			form := url.Values{}
-			form.Add("name", tt.userName)
-			form.Add("email", tt.userEmail)
-			form.Add("password", tt.userPassword)
-			form.Add("csrf_token", tt.csrfToken)
+			form.Add("slug", tt.slug)
+			form.Add("password", tt.password)
+			form.Add("max_views", tt.maxViews)
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji, but slowly, slowly!")
+			form.Add("language", "plaintext")
+			form.Add("visibility", "public")
+			form.Add("expires", "7")
+			form.Add("csrf_token", validCSRFToken)
 
-			code, _, body := ts.postForm(t, "/user/signup", form)
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
 
 			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
 
-			if tt.wantFormTag != "" {
-				assert.StringContains(t, body, tt.wantFormTag)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Only logged in users can fork a snippet.
+	code, headers, _ := ts.get(t, "/snippet/fork/silentpond")
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			urlPath:  "/snippet/fork/silentpond",
+			wantCode: http.StatusOK,
+			wantBody: "<input type='hidden' name='parent_id' value='1'>",
+		},
+		{
+			name:     "Own private snippet",
+			urlPath:  "/snippet/fork/dbpassword",
+			wantCode: http.StatusOK,
+			wantBody: "<textarea name='content'>hunter2</textarea>",
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/snippet/fork/nosuchsnippet",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Protected snippet",
+			urlPath:  "/snippet/fork/lockedbox",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "View limited snippet",
+			urlPath:  "/snippet/fork/burnnote",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			assert.StringNotContains(t, body, "The key is under the mat")
+			assert.StringNotContains(t, body, "The eagle lands at midnight")
+		})
+	}
+}
+
+func TestSnippetForkPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/fork/silentpond")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		parentID     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid",
+			parentID:     "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newsnippet",
+		},
+		{
+			name:     "Non-existent parent",
+			parentID: "99",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "The snippet you were forking is no longer available.",
+		},
+		{
+			name:     "View limited parent",
+			parentID: "10",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "The snippet you were forking is no longer available.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("parent_id", tt.parentID)
+			form.Add("title", "An old silent pond")
+			form.Add("content", "An old silent pond... a frog jumps in.")
+			form.Add("language", "plaintext")
+			form.Add("visibility", "public")
+			form.Add("expires", "7")
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				// The link to the parent is dropped, so that publishing
+				// again creates a plain new snippet.
+				assert.StringNotContains(t, body, "name='parent_id'")
+			}
+		})
+	}
+}
+
+func TestSnippetViewForks(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Only the public fork is listed; the unlisted one belongs to bob.
+	code, _, body := ts.get(t, "/snippet/view/silentpond")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/snippet/view/pondremix'>An old silent pond (remix)</a>")
+	assert.StringNotContains(t, body, "pondsecret")
+	assert.StringNotContains(t, body, "/snippet/fork/silentpond")
+
+	code, _, body = ts.get(t, "/snippet/view/pondremix")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Forked from <a href='/snippet/view/silentpond'>#1</a>")
+
+	// A snippet which isn't a fork, and a fork whose parent has been
+	// deleted, don't say where they came from.
+	_, _, body = ts.get(t, "/snippet/view/silentpond")
+
+	assert.StringNotContains(t, body, "Forked from")
+
+	code, _, body = ts.get(t, "/snippet/view/orphanfork")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringNotContains(t, body, "Forked from")
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/snippet/view/silentpond")
+
+	assert.StringContains(t, body, "<a href='/snippet/fork/silentpond'>Fork</a>")
+}
+
+func TestSnippetViewPrivate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The private snippet belongs to alice, so once she's logged in she can
+	// see it.
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/view/dbpassword")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "hunter2")
+	assert.StringContains(t, body, "<em class='visibility'>private</em>")
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Until the snippet is unlocked, only its title and the unlock form are
+	// shown, and the same goes for its history.
+	code, _, body := ts.get(t, "/snippet/view/lockedbox")
+
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "<form action='/snippet/unlock/lockedbox' method='POST' novalidate>")
+	assert.StringContains(t, body, "Locked box")
+	assert.StringNotContains(t, body, "The key is under the mat")
+
+	code, _, _ = ts.get(t, "/snippet/view/lockedbox/history")
+
+	assert.Equal(t, code, http.StatusForbidden)
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	unlock := func(password string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", validCSRFToken)
+		return ts.postForm(t, "/snippet/unlock/lockedbox", form)
+	}
+
+	code, _, body = unlock("")
+
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	code, _, body = unlock("wrong password")
+
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "The password is incorrect")
+
+	code, headers, _ := unlock("open sesame")
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/lockedbox")
+
+	code, _, body = ts.get(t, "/snippet/view/lockedbox")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The key is under the mat")
+}
+
+func TestSnippetUnlockThrottle(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/lockedbox")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("password", "wrong password")
+	form.Add("csrf_token", validCSRFToken)
+
+	for range 5 {
+		code, _, _ := ts.postForm(t, "/snippet/unlock/lockedbox", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// Once the attempts are used up, even the right password is refused.
+	form.Set("password", "open sesame")
+
+	code, _, body := ts.postForm(t, "/snippet/unlock/lockedbox", form)
+
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many incorrect passwords")
+}
+
+func TestSnippetReveal(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Following the link only shows the confirmation page, so that the
+	// snippet isn't burned by accident.
+	code, _, body := ts.get(t, "/snippet/view/burnnote")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='/snippet/reveal/burnnote' method='POST'>")
+	assert.StringContains(t, body, "This snippet will be destroyed as soon as it has been viewed.")
+	assert.StringNotContains(t, body, "The eagle lands at midnight")
+
+	// Nor can it be read through its history.
+	code, _, _ = ts.get(t, "/snippet/view/burnnote/history")
+
+	assert.Equal(t, code, http.StatusNotFound)
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, body := ts.postForm(t, "/snippet/reveal/burnnote", form)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Cache-Control"), "no-store")
+	assert.StringContains(t, body, "The eagle lands at midnight")
+	assert.StringContains(t, body, "This snippet has now been destroyed.")
+
+	// Snippets without a view limit are just redirected to.
+	code, headers, _ = ts.postForm(t, "/snippet/reveal/silentpond", form)
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/silentpond")
+}
+
+func TestSnippetExport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantType        string
+		wantDisposition string
+		wantBody        string
+	}{
+		{
+			name:     "Raw",
+			urlPath:  "/snippet/raw/silentpond",
+			wantCode: http.StatusOK,
+			wantType: "text/plain; charset=utf-8",
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:            "Download",
+			urlPath:         "/snippet/download/silentpond",
+			wantCode:        http.StatusOK,
+			wantType:        "text/plain; charset=utf-8",
+			wantDisposition: `attachment; filename=an-old-silent-pond.txt`,
+			wantBody:        "An old silent pond...",
+		},
+		{
+			name:            "Download Markdown",
+			urlPath:         "/snippet/download/runbook",
+			wantCode:        http.StatusOK,
+			wantType:        "text/plain; charset=utf-8",
+			wantDisposition: `attachment; filename=restarting-the-server.md`,
+			wantBody:        "<script>alert(1)</script>",
+		},
+		{
+			name:            "HTML export",
+			urlPath:         "/snippet/export/silentpond/html",
+			wantCode:        http.StatusOK,
+			wantType:        "text/html; charset=utf-8",
+			wantDisposition: `attachment; filename=an-old-silent-pond.html`,
+			wantBody:        "<h1>An old silent pond</h1>",
+		},
+		{
+			name:            "HTML export of Markdown",
+			urlPath:         "/snippet/export/runbook/html",
+			wantCode:        http.StatusOK,
+			wantType:        "text/html; charset=utf-8",
+			wantDisposition: `attachment; filename=restarting-the-server.html`,
+			wantBody:        "<code>make restart</code>",
+		},
+		{
+			name:            "Markdown export",
+			urlPath:         "/snippet/export/silentpond/markdown",
+			wantCode:        http.StatusOK,
+			wantType:        "text/markdown; charset=utf-8",
+			wantDisposition: `attachment; filename=an-old-silent-pond.md`,
+			wantBody:        "# An old silent pond\n\n```\nAn old silent pond...\n```\n",
+		},
+		{
+			name:            "JSON export",
+			urlPath:         "/snippet/export/silentpond/json",
+			wantCode:        http.StatusOK,
+			wantType:        "application/json",
+			wantDisposition: `attachment; filename=an-old-silent-pond.json`,
+			wantBody:        `"slug": "silentpond"`,
+		},
+		{
+			name:     "Unknown format",
+			urlPath:  "/snippet/export/silentpond/pdf",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Expired",
+			urlPath:  "/snippet/raw/wintryforest",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/download/dbpassword",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Protected",
+			urlPath:  "/snippet/raw/lockedbox",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "View limited",
+			urlPath:  "/snippet/export/burnnote/json",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				assert.StringNotContains(t, body, "The key is under the mat")
+				assert.StringNotContains(t, body, "The eagle lands at midnight")
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), tt.wantType)
+			assert.Equal(t, headers.Get("X-Content-Type-Options"), "nosniff")
+			assert.Equal(t, headers.Get("Content-Disposition"), tt.wantDisposition)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetExportPrivate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The creator of a private snippet can export it, but it mustn't be
+	// cached.
+	ts.login(t)
+
+	code, headers, body := ts.get(t, "/snippet/raw/dbpassword")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Cache-Control"), "no-store")
+	assert.Equal(t, body, "hunter2")
+
+	// Public snippets can be cached as normal.
+	_, headers, _ = ts.get(t, "/snippet/raw/silentpond")
+
+	assert.Equal(t, headers.Get("Cache-Control"), "")
+}
+
+func TestHighlightStylesheet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/static/css/highlight.css")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "text/css; charset=utf-8")
+	assert.StringContains(t, body, ".chroma")
+}
+
+func TestUserSignup(t *testing.T) {
+	// Create the application struct containg our mocked dependencies and set
+	// up the test seerver for running an end-to-end test.
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Make a GET /user/signup request and then extract the CSRF token from the
+	// response body.
+	_, _, body := ts.get(t, "/user/signup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName     = "Bob"
+		validPassword = "validPa$$word"
+		validEmail    = "bob@example.com"
+		formTag       = "<form action='/user/signup' method='POST' novalidate>"
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantFormTag  string
+	}{
+		{
+			name:         "Valid submission",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusSeeOther,
+		},
+		{
+			name:         "Invalid CSRF Token",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    "wrongToken",
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:         "Empty name",
+			userName:     "",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Empty email",
+			userName:     validName,
+			userEmail:    "",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Empty password",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Invalid email",
+			userName:     validName,
+			userEmail:    "bob@example.",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Short passwprd",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "pa$$",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate email",
+			userName:     validName,
+			userEmail:    "dupe@example.com",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFormTag != "" {
+				assert.StringContains(t, body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestUserLoginPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid credentials",
+			email:    "alice@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Wrong password",
+			email:    "alice@example.com",
+			password: "wrong",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Email or password is incorrect",
+		},
+		{
+			name:     "Disabled account",
+			email:    "disabled@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Your account has been disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/user/login", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/account/view")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/account/view")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "alice@example.com")
+		assert.StringContains(t, body, "CI deploys")
+	})
+}
+
+func TestAccountTokenCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/view")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		tokenName string
+		scopes    []string
+		expires   string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Valid submission",
+			tokenName: "Laptop",
+			scopes:    []string{"snippets:read", "snippets:write"},
+			expires:   "30",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Empty name",
+			tokenName: "",
+			scopes:    []string{"snippets:read"},
+			expires:   "30",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field cannot be blank",
+		},
+		{
+			name:      "No scopes",
+			tokenName: "Laptop",
+			expires:   "30",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "You must choose at least one scope",
+		},
+		{
+			name:      "Unknown scope",
+			tokenName: "Laptop",
+			scopes:    []string{"admin"},
+			expires:   "30",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field contains an unknown scope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.tokenName)
+			for _, scope := range tt.scopes {
+				form.Add("scopes", scope)
+			}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/account/tokens/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountNewTokenShownOnce(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/view")
+
+	form := url.Values{}
+	form.Add("name", "Laptop")
+	form.Add("scopes", "snippets:read")
+	form.Add("expires", "30")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/account/tokens/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/account/view")
+	assert.StringContains(t, body, "NEWTOKENNEWTOKENNEWTOKENNE")
+
+	_, _, body = ts.get(t, "/account/view")
+	if strings.Contains(body, "NEWTOKENNEWTOKENNEWTOKENNE") {
+		t.Error("new token was shown more than once")
+	}
+}
+
+func TestUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/user/snippets")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/user/snippets")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<a href='/snippet/view/silentpond'>An old silent pond</a>")
+		// Expired snippets are listed, but not linked.
+		assert.StringContains(t, body, "<td>Over the wintry forest</td>")
+		// They can still be deleted from here, though.
+		assert.StringContains(t, body, "<form action='/snippet/delete/wintryforest' method='POST'>")
+		assert.StringNotContains(t, body, "<form action='/snippet/delete/silentpond' method='POST'>")
+	})
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/edit/silentpond")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Own snippet",
+			urlPath:  "/snippet/edit/silentpond",
+			wantCode: http.StatusOK,
+			wantBody: "<input type='text' name='tags' value='haiku, poetry'>",
+		},
+		{
+			name:     "Another user's snippet",
+			urlPath:  "/snippet/edit/autumnmorn",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/edit/nosuchsnippet",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetEditPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/edit/silentpond")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		title        string
+		content      string
+		tags         string
+		expires      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			urlPath:      "/snippet/edit/silentpond",
+			title:        "An old silent pond",
+			content:      "An old silent pond... a frog jumps in",
+			tags:         "Haiku, poetry, haiku,",
+			expires:      "7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/silentpond",
+		},
+		{
+			name:     "Empty title",
+			urlPath:  "/snippet/edit/silentpond",
+			title:    "",
+			content:  "An old silent pond...",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "<form action='/snippet/edit/silentpond' method='POST'>",
+		},
+		{
+			name:     "Too many tags",
+			urlPath:  "/snippet/edit/silentpond",
+			title:    "An old silent pond",
+			content:  "An old silent pond...",
+			tags:     "a, b, c, d, e, f",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot have more than 5 tags",
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/snippet/edit/silentpond",
+			title:    "An old silent pond",
+			content:  "An old silent pond...",
+			tags:     "haiku, <script>",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Tags can only contain letters, digits and",
+		},
+		{
+			name:     "Another user's snippet",
+			urlPath:  "/snippet/edit/autumnmorn",
+			title:    "Mine now",
+			content:  "All mine",
+			expires:  "7",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("language", "plaintext")
+			form.Add("tags", tt.tags)
+			form.Add("visibility", "public")
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetDeletePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/silentpond")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Own snippet",
+			urlPath:      "/snippet/delete/silentpond",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/snippets",
+		},
+		{
+			name:         "Own expired snippet",
+			urlPath:      "/snippet/delete/wintryforest",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/snippets",
+		},
+		{
+			name:     "Another user's snippet",
+			urlPath:  "/snippet/delete/autumnmorn",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/delete/nosuchsnippet",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestUserTrash(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/user/trash")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The light of a candle")
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Snippet in trash",
+			urlPath:      "/user/trash/restore/5",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/snippets",
+		},
+		{
+			name:     "Snippet not in trash",
+			urlPath:  "/user/trash/restore/4",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "History",
+			urlPath:  "/snippet/view/silentpond/history",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/silentpond/diff?to=2'>Compare with previous</a>",
+		},
+		{
+			name:     "History of non-existent snippet",
+			urlPath:  "/snippet/view/nosuchsnippet/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Default diff",
+			urlPath:  "/snippet/view/silentpond/diff",
+			wantCode: http.StatusOK,
+			wantBody: "<tr class='diff-delete'>",
+		},
+		{
+			name:     "Explicit diff",
+			urlPath:  "/snippet/view/silentpond/diff?from=1&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "<tr class='diff-insert'>",
+		},
+		{
+			name:     "Non-existent version",
+			urlPath:  "/snippet/view/silentpond/diff?from=1&to=9",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid version",
+			urlPath:  "/snippet/view/silentpond/diff?from=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
 			}
 		})
 	}
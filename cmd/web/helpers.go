@@ -2,11 +2,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"wakisa.com/internal/models"
+
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
 )
@@ -62,10 +68,12 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 func (app *application) newTemplateData(r *http.Request) templateData {
 	return templateData{
 		CurrentYear: time.Now().Year(),
+		Languages:   languages,
 		// Add the flash message to the template data, if one exists.
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
 	}
 }
 
@@ -101,6 +109,45 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// The readCursor() helper reads the pagination parameters for a list of
+// snippets from the query string. The before and after parameters hold the
+// ID of a snippet to page from, and limit is the page size. Missing
+// parameters are left as zero, which the model treats as "the first page" and
+// "the default page size".
+func (app *application) readCursor(r *http.Request) (models.Cursor, error) {
+	var c models.Cursor
+
+	qs := r.URL.Query()
+
+	params := []struct {
+		key string
+		dst *int
+	}{
+		{"before", &c.Before},
+		{"after", &c.After},
+		{"limit", &c.Limit},
+	}
+
+	for _, p := range params {
+		if !qs.Has(p.key) {
+			continue
+		}
+
+		n, err := strconv.Atoi(qs.Get(p.key))
+		if err != nil || n < 1 {
+			return models.Cursor{}, fmt.Errorf("the %s parameter must be a positive integer", p.key)
+		}
+
+		*p.dst = n
+	}
+
+	if c.Before > 0 && c.After > 0 {
+		return models.Cursor{}, errors.New("the before and after parameters can't be used together")
+	}
+
+	return c, nil
+}
+
 // Return true if the current request is from an authenticated user, otherwise
 // return false.
 func (app *application) isAuthenticated(r *http.Request) bool {
@@ -111,3 +158,98 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// Return the ID of the authenticated user making the request, or 0 if the
+// request isn't authenticated.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// The writeJSON() helper encodes data as JSON and sends it with the given
+// status code and any additional headers. Like render(), it encodes into a
+// buffer first so that an encoding error can still become a clean 500.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// Append a newline to make the output easier to read in a terminal.
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// The readJSON() helper decodes a JSON request body into dst. It limits the
+// size of the body, rejects unknown fields and trailing data, and turns the
+// errors from encoding/json into messages that are safe to send back to an
+// API client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	// Limit the request body to 1MB.
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+
+		// As with decodePostForm(), passing an invalid destination is a bug in
+		// our code rather than a client error, so we panic.
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+
+		default:
+			return err
+		}
+	}
+
+	// Call Decode() again to make sure the body only contained a single JSON
+	// value.
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
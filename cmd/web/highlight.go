@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Define a language type to hold a language which snippets can be written
+// in. The Value is stored in the database and is also the name of the chroma
+// lexer used to highlight the snippet, while the Name is shown to users. Ext
+// is the file extension given to downloaded snippets.
+type language struct {
+	Value string
+	Name  string
+	Ext   string
+}
+
+// The languages that users can choose from when creating a snippet, in the
+// order they appear in the form.
+var languages = []language{
+	{"plaintext", "Plain text", ".txt"},
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"css", "CSS", ".css"},
+	{"diff", "Diff", ".diff"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"markdown", "Markdown", ".md"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// The languageExt() function returns the file extension for a language, or
+// .txt if it isn't one we know.
+func languageExt(value string) string {
+	for _, l := range languages {
+		if l.Value == value {
+			return l.Ext
+		}
+	}
+	return ".txt"
+}
+
+// The languageValues() function returns the value of every language, for use
+// with validator.PermittedValue().
+func languageValues() []string {
+	values := make([]string, len(languages))
+	for i, l := range languages {
+		values[i] = l.Value
+	}
+	return values
+}
+
+// All snippets are highlighted with the same style. Note that we only use the
+// style to generate the stylesheet: the highlighted HTML itself uses CSS
+// classes rather than inline styles, which our Content-Security-Policy
+// wouldn't allow.
+var (
+	highlightStyle     = styles.Get("github")
+	highlightFormatter = chromahtml.New(chromahtml.WithClasses(true))
+)
+
+// The highlight() template function renders the content of a snippet as
+// syntax highlighted HTML. Chroma escapes the content as it goes, so it's
+// safe to return it as template.HTML. Unknown languages are rendered as
+// plain text.
+func highlight(content, lang string) (template.HTML, error) {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	err = highlightFormatter.Format(&buf, highlightStyle, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
+// The stylesheet for the highlighted HTML is generated from the chroma style
+// the first time it's requested, and then reused.
+var highlightCSS = sync.OnceValues(func() ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := highlightFormatter.WriteCSS(&buf, highlightStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+})
+
+func (app *application) highlightStylesheet(w http.ResponseWriter, r *http.Request) {
+	css, err := highlightCSS()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write(css)
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	// Import the models package that we just created. You need to prefix
@@ -18,7 +24,10 @@ import (
 	"wakisa.com/internal/models"
 
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -31,46 +40,94 @@ type application struct {
 	logger         *slog.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	trashRetention time.Duration
+	unlockLimiter  *attemptLimiter
+	// wg tracks the background goroutines, so that we can wait for them to
+	// finish before the application exits.
+	wg sync.WaitGroup
 }
 
 func main() {
 
-	// Define a new command-line flag with the name 'addr', a
-	// default value of ":4000" and some short help text explaining
-	// what the flag controls. The value of the flag will be stored
-	// in the addr variable at runtime.
-	addr := flag.String("addr", ":4000", "HTTP network address")
+	// Load the configuration from the command-line flags, the environment and
+	// the config file (if there is one). See config.go for how they fit
+	// together. The -h flag prints our own usage message, which also lists
+	// the environment variables.
+	cfg, fs, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			usage(os.Stdout, fs)
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		usage(os.Stderr, fs)
+		os.Exit(2)
+	}
 
-	// Define a new command-line flag for the MYSQL DSN string
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MYSQL data source name")
+	if cfg.printConfig {
+		err = printConfig(os.Stdout, fs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// The migrate subcommand works on the database and then exits, without
+	// starting the server, so it doesn't need the rest of the settings to be
+	// valid (there's no point insisting on a TLS certificate, for example).
+	if len(cfg.command) > 0 {
+		err = runMigrate(os.Stdout, cfg, cfg.command[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if errors.Is(err, errUsage) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
-	// Importantly, we use the flag.Parse() function to parse the command-line
-	//flag. This reads in the command-line flag value and assigns it
-	// to the addr variable. You need to call this *before* you use
-	// the addr variable otherwise it will always contain the default
-	// value of ":4000". If any errors are encoutentered during
-	// parsing the application will be terminated.
-	flag.Parse()
+	// Check all the settings up front, so that a mistake stops the
+	// application straight away.
+	err = cfg.validate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(2)
+	}
 
 	// Use the slog.New() function to initialize a new structured logger
 	// which writes to the standard out stream and uses the default settings
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	// To keep the main() function tidy I've put the code for creating a
-	// connection pool into the separate openDB() function below. We pass
-	// openDB() the DSN from the command-line flag.
-	db, err := openDB(*dsn)
-	if err != nil {
-		logger.Error(err.Error())
-		os.Exit(1)
-	}
+	// Open a connection pool to the configured database. The code for this
+	// lives in the models package, as the admin tool needs it too. The
+	// in-memory store doesn't use a database at all, in which case db stays
+	// nil.
+	var db *sql.DB
+
+	if cfg.store == "database" {
+		db, err = models.Open(cfg.db.driver, cfg.db.dsn)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
 
-	// We also defer a call to db.Close(), so that the connection pool is closed
-	// before the main() function exits.
-	defer db.Close()
+		// We also defer a call to db.Close(), so that the connection pool is
+		// closed before the main() function exits.
+		defer db.Close()
+
+		// Make sure the schema is up to date, or at least warn if it isn't.
+		err = migrateOnStartup(logger, db, cfg.db.driver, cfg.autoMigrate)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
 
 	// Initialize a new template cache...
 	templateCache, err := newTemplateCache()
@@ -82,27 +139,56 @@ func main() {
 	// Initializea decoder instance...
 	formDecoder := form.NewDecoder()
 
+	// Create the models and the session store for where we're storing data.
+	snippets, users, tokens, sessionStore, err := newStores(cfg, db)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	// Use the scs.New() function to initalize a new session manager. Then we
-	// configure it to use our MYSQL database as the session store, and set a
-	// lifetime of 12 hours (so that sessions automatically expire 12 hours
-	// after first being created).
+	// configure it to store sessions in our database, and set their lifetime
+	// (so that sessions automatically expire that long after first being
+	// created).
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Store = sessionStore
+	sessionManager.Lifetime = cfg.session.lifetime
 	//Make sure that the Secure attributeis set on our session cookies.
 	// Setting this means that the cookie will only be sent by a user's web
 	// browser when a HTTPS connection is being used (and won't be sent over an
 	// unsecure HTTP connection).
-	sessionManager.Cookie.Secure = true
+	sessionManager.Cookie.Secure = cfg.session.secure
 
 	// And add the session manager to our application dependencies.
 	app := &application{
 		logger:         logger,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		snippets:       snippets,
+		users:          users,
+		tokens:         tokens,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		trashRetention: cfg.trashRetention,
+		// Allow 5 wrong guesses at a protected snippet's password every 15
+		// minutes from each IP address.
+		unlockLimiter: newAttemptLimiter(5, 15*time.Minute),
+	}
+
+	// Create a context which is cancelled when the application receives a
+	// SIGINT (Ctrl+C) or SIGTERM signal. The server and the background
+	// goroutines all watch it, so that they can stop cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Start a background goroutine which permanently deletes snippets once
+	// they've expired, or been in the trash for longer than the retention
+	// window. It's the only thing which empties the trash, so with the purge
+	// turned off deleted snippets are kept (and can be restored) until it's
+	// turned back on.
+
+	if cfg.purge.interval > 0 {
+		app.wg.Add(1)
+		go app.purge(ctx, cfg.purge.interval, cfg.purge.batchSize)
 	}
 
 	// INitialize a tls.Config struct to hold the non-default TLS settings we
@@ -116,7 +202,7 @@ func main() {
 	// Set the server's TlSConfig field to use the tlsConfig variable we just
 	// created.
 	srv := &http.Server{
-		Addr:    *addr,
+		Addr:    cfg.addr,
 		Handler: app.routes(),
 		// Create a *log.Logger from our structured logger handler, which writes
 		// log entries at Error level, and assign it to the ErrorLog field. If
@@ -125,35 +211,80 @@ func main() {
 		ErrorLog:  slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		TLSConfig: tlsConfig,
 		// Add Idle, read and write timeouts to the server.
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.timeouts.idle,
+		ReadTimeout:  cfg.timeouts.read,
+		WriteTimeout: cfg.timeouts.write,
 	}
 
 	logger.Info("starting server", "addr", srv.Addr)
 
-	// Use the ListenAndServeTLS() method to start the HTTPS sever. We
-	// pass in the paths to the TLS certificate and corrensponding private key as
-	// the two parameters.
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	logger.Error(err.Error())
-	os.Exit(1)
+	// Use the serve() method to start the HTTPS sever. We pass in the paths
+	// to the TLS certificate and corrensponding private key, and it runs
+	// until the server fails or we're signalled to stop.
+	err = app.serve(ctx, srv, cfg.tls.certFile, cfg.tls.keyFile, cfg.timeouts.shutdown)
 
-}
+	// Either way, stop the background goroutines and wait for them to finish
+	// whatever they're in the middle of, along with the session store's
+	// cleanup goroutine, before the connection pool is closed.
+	stop()
+	app.wg.Wait()
+	sessionStore.StopCleanup()
 
-// The openDB() function wraps sql.OPen() and returns a sql.DB connection pool
-// for a given DSN.
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		return nil, err
+		logger.Error(err.Error())
+		// os.Exit() doesn't run deferred functions, so we close the
+		// connection pool ourselves.
+		if db != nil {
+			db.Close()
+		}
+		os.Exit(1)
 	}
 
-	err = db.Ping()
+	logger.Info("stopped server")
+
+}
+
+// Define a sessionStore interface for the scs stores we use, all of which
+// have a cleanup goroutine that needs to be stopped when we shut down.
+type sessionStore interface {
+	scs.Store
+	StopCleanup()
+}
+
+// The newStores() function returns the models and the session store for the
+// configured store: either the in-memory store, seeded from the fixtures file
+// if there is one, or the database driver's models on top of db.
+func newStores(cfg config, db *sql.DB) (models.SnippetModelInterface, models.UserModelInterface, models.TokenModelInterface, sessionStore, error) {
+	if cfg.store == "memory" {
+		mem := models.NewMemoryDB()
+
+		if cfg.fixtures != "" {
+			err := loadFixtures(mem, cfg.fixtures)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+
+		return &models.MemorySnippetModel{DB: mem}, &models.MemoryUserModel{DB: mem}, &models.MemoryTokenModel{DB: mem}, memstore.New(), nil
+	}
+
+	switch cfg.db.driver {
+	case "sqlite":
+		return &models.SQLiteSnippetModel{DB: db}, &models.SQLiteUserModel{DB: db}, &models.SQLiteTokenModel{DB: db}, sqlite3store.New(db), nil
+	case "postgres":
+		return &models.PostgresSnippetModel{DB: db}, &models.PostgresUserModel{DB: db}, &models.PostgresTokenModel{DB: db}, postgresstore.New(db), nil
+	}
+
+	return &models.SnippetModel{DB: db}, &models.UserModel{DB: db}, &models.TokenModel{DB: db}, mysqlstore.New(db), nil
+}
+
+// The loadFixtures() function seeds the in-memory store from a fixtures file.
+func loadFixtures(mem *models.MemoryDB, path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		db.Close()
-		return nil, err
+		return err
 	}
+	defer f.Close()
 
-	return db, nil
+	return mem.LoadFixtures(f)
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// The Markdown converter supports GitHub Flavored Markdown (tables, task
+// lists, strikethrough and autolinks). We let goldmark pass any raw HTML in
+// the source through untouched, because everything it produces is run through
+// the sanitizer below before it gets anywhere near a page.
+var markdownConverter = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// The sanitizer policy is based on bluemonday's policy for user generated
+// content, which strips <script> and <style> elements, event handler
+// attributes like onclick, and inline styles. On top of that we only allow
+// links and images with http, https or mailto URLs, so that javascript: and
+// data: URLs can't be smuggled in.
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}()
+
+// The markdown() template function renders Markdown source as HTML. The
+// output of goldmark isn't trusted until it has been sanitized, and only then
+// is it returned as template.HTML.
+func markdown(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+
+	err := markdownConverter.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"wakisa.com/internal/models"
 
 	"github.com/justinas/nosurf"
 )
@@ -114,8 +118,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// coming from an authenticated user who exists in our database. We
 		// create a new copy of the request (with an isAuthenticatedContextKey
 		// value of true in the request context) and assign it to r.
+		// We also store the user's ID, so that handlers can tell who is
+		// making the request.
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
@@ -123,3 +130,109 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// The apiAuthenticate() middleware is the API counterpart to authenticate().
+// API clients don't carry a session cookie, so instead we look for HTTP Basic
+// Auth credentials and check them against the users table on every request.
+// Requests without an Authorization header carry on unauthenticated.
+func (app *application) apiAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// If credentials were provided but don't match, fail straight away
+		// rather than silently treating the request as anonymous. A disabled
+		// account is refused in the same way.
+		id, err := app.users.Authenticate(email, password)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) || errors.Is(err, models.ErrAccountDisabled) {
+				app.apiInvalidCredentials(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The authenticateToken() middleware sits alongside authenticate() and
+// apiAuthenticate(), and accepts personal access tokens sent in an
+// "Authorization: Bearer <token>" header. A valid token marks the request as
+// authenticated using the same isAuthenticatedContextKey as a login session,
+// and the token itself is stored in the request context so that its scopes
+// can be checked later.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response varies depending on the Authorization header, so make
+		// sure that caches know about it.
+		w.Header().Add("Vary", "Authorization")
+
+		scheme, plaintext, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := app.tokens.GetForToken(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.apiInvalidToken(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, token.UserID)
+		ctx = context.WithValue(ctx, tokenContextKey, token)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The apiRequireScope() function returns a middleware which checks that a
+// request authenticated with a bearer token has been granted the given scope.
+// Requests authenticated any other way act with the full permissions of the
+// user, so they are let through.
+func (app *application) apiRequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(tokenContextKey).(models.Token)
+			if ok && !token.HasScope(scope) {
+				app.apiError(w, r, http.StatusForbidden, fmt.Sprintf("this token does not have the %q scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The apiRequireAuthentication() middleware works like requireAuthentication(),
+// except that there's no login page to redirect to, so unauthenticated
+// requests get a 401 response in the JSON error envelope.
+func (app *application) apiRequireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiAuthenticationRequired(w, r)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
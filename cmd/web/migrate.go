@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+
+	"wakisa.com/internal/models"
+)
+
+// The migrate subcommand manages the database schema, using the migrations
+// embedded in the models package. It takes the same flags as the server, so
+// it works on whichever database the server is configured to use:
+//
+//	web migrate up          apply every pending migration
+//	web migrate down [n]    roll back the last n migrations (default 1)
+//	web migrate status      list the migrations and whether they're applied
+
+// errUsage is returned when a subcommand is used wrongly, so that main() can
+// exit with the same status as for a bad flag.
+var errUsage = errors.New("usage: web [flags] migrate up|down [n]|status")
+
+// The runMigrate() function runs the migrate subcommand with the given
+// arguments, writing its report to w.
+func runMigrate(w io.Writer, cfg config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	action, steps := args[0], 1
+
+	switch {
+	case (action == "up" || action == "status") && len(args) == 1:
+	case action == "down" && len(args) == 1:
+	case action == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("%w: n must be a positive number", errUsage)
+		}
+		steps = n
+	default:
+		return errUsage
+	}
+
+	if cfg.store != "database" {
+		return errors.New("migrate only works with the database store")
+	}
+
+	if _, ok := defaultDSNs[cfg.db.driver]; !ok {
+		return errors.New("db-driver must be mysql, postgres or sqlite")
+	}
+
+	db, err := models.Open(cfg.db.driver, cfg.db.dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m := &models.Migrator{DB: db, Driver: cfg.db.driver}
+
+	switch action {
+	case "up":
+		done, err := m.Up()
+		for _, migration := range done {
+			fmt.Fprintf(w, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		done, err := m.Down(steps)
+		for _, migration := range done {
+			fmt.Fprintf(w, "rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "no migrations to roll back")
+		}
+		return err
+
+	default:
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+
+		for _, s := range statuses {
+			applied := "pending"
+			if s.IsApplied() {
+				applied = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+
+		return tw.Flush()
+	}
+}
+
+// The migrateOnStartup() function is called by main() before the server
+// starts. With -auto-migrate it applies any pending migrations; otherwise it
+// just warns if there are some, as the application is unlikely to work
+// properly until they've been applied.
+func migrateOnStartup(logger *slog.Logger, db *sql.DB, driver string, auto bool) error {
+	m := &models.Migrator{DB: db, Driver: driver}
+
+	if auto {
+		done, err := m.Up()
+		for _, migration := range done {
+			logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		return err
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		logger.Warn("database schema is out of date; run the migrate up command or start with -auto-migrate", "pending", len(pending))
+	}
+
+	return nil
+}
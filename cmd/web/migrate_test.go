@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wakisa.com/internal/assert"
+	"wakisa.com/internal/models"
+)
+
+func TestRunMigrate(t *testing.T) {
+	// Use a fresh SQLite database, so that the test doesn't need a database
+	// server.
+	cfg, _, err := loadConfig([]string{"-db-driver", "sqlite", "-dsn", filepath.Join(t.TempDir(), "test.db")}, fakeEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) string {
+		var buf bytes.Buffer
+
+		err := runMigrate(&buf, cfg, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return buf.String()
+	}
+
+	// Work out what to expect from the migrations themselves, so the test
+	// doesn't need changing every time one is added.
+	migrations, err := models.Migrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []string
+	for _, m := range migrations {
+		applied = append(applied, fmt.Sprintf("applied %04d_%s\n", m.Version, m.Name))
+	}
+	last := migrations[len(migrations)-1]
+
+	out := run("status")
+	assert.Equal(t, strings.Count(out, "pending"), len(migrations))
+
+	out = run("up")
+	assert.Equal(t, out, strings.Join(applied, ""))
+
+	out = run("up")
+	assert.Equal(t, out, "no pending migrations\n")
+
+	out = run("status")
+	assert.Equal(t, strings.Count(out, "pending"), 0)
+
+	out = run("down")
+	assert.Equal(t, out, fmt.Sprintf("rolled back %04d_%s\n", last.Version, last.Name))
+
+	out = run("status")
+	assert.Equal(t, strings.Count(out, "pending"), 1)
+	assert.StringContains(t, out, last.Name)
+
+	out = run("down", strconv.Itoa(len(migrations)+1))
+	assert.Equal(t, strings.Count(out, "rolled back"), len(migrations)-1)
+	assert.StringContains(t, out, "rolled back 0001_initial_schema\n")
+
+	out = run("down")
+	assert.Equal(t, out, "no migrations to roll back\n")
+}
+
+func TestRunMigrateErrors(t *testing.T) {
+	cfg, _, err := loadConfig([]string{"-db-driver", "sqlite", "-dsn", filepath.Join(t.TempDir(), "test.db")}, fakeEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Usage mistakes are reported as errUsage, so main() can exit with
+	// status 2.
+	for _, args := range [][]string{nil, {"sideways"}, {"up", "2"}, {"down", "0"}, {"down", "x"}, {"down", "1", "2"}} {
+		err := runMigrate(&bytes.Buffer{}, cfg, args)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("args %q: got %v; want errUsage", args, err)
+		}
+	}
+
+	cfg.store = "memory"
+
+	err = runMigrate(&bytes.Buffer{}, cfg, []string{"up"})
+	if err == nil || errors.Is(err, errUsage) {
+		t.Errorf("got %v; want a store error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The purge() method runs in the background until the context is cancelled.
+// Straight away, and then on every tick of the interval, it permanently
+// deletes the snippets which have expired or which have been in the trash for
+// longer than the retention window. The caller should add the goroutine to
+// app.wg, so that main() can wait for it to finish before exiting.
+func (app *application) purge(ctx context.Context, interval time.Duration, batchSize int) {
+	defer app.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.purgeOnce(ctx, batchSize)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The purgeOnce() method does a single round of purging. A panic in a
+// background goroutine isn't caught by our recoverPanic() middleware, and
+// would bring down the whole application. So we recover it here and log it
+// instead, which leaves purge() to carry on with the next round rather than
+// stopping for good.
+func (app *application) purgeOnce(ctx context.Context, batchSize int) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Sprintf("%v", err))
+		}
+	}()
+
+	app.purgeTrash()
+	app.purgeExpired(ctx, batchSize)
+}
+
+// The purgeTrash() method deletes the snippets which have been in the trash
+// for longer than the retention window.
+func (app *application) purgeTrash() {
+	n, err := app.snippets.Purge(app.trashRetention)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	if n > 0 {
+		app.logger.Info("purged snippets from the trash", "count", n)
+	}
+}
+
+// The purgeExpired() method deletes the snippets which have expired, in
+// batches of batchSize. It keeps going until a batch comes back smaller than
+// batchSize, which means there's nothing left to delete, but checks the
+// context between batches so that a big backlog doesn't hold up shutdown.
+func (app *application) purgeExpired(ctx context.Context, batchSize int) {
+	var total, batches int
+
+	for ctx.Err() == nil {
+		n, err := app.snippets.PurgeExpired(batchSize)
+		if err != nil {
+			app.logger.Error(err.Error())
+			break
+		}
+
+		total += n
+		batches++
+
+		if n < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.Info("purged expired snippets", "count", total, "batches", batches)
+	}
+}
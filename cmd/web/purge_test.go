@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wakisa.com/internal/assert"
+	"wakisa.com/internal/models/mocks"
+)
+
+// Define a backlogSnippetModel type which pretends there are a number of
+// expired snippets waiting to be purged, and counts the batches it's asked to
+// delete.
+type backlogSnippetModel struct {
+	mocks.SnippetModel
+	expired int
+	batches int
+}
+
+func (m *backlogSnippetModel) PurgeExpired(limit int) (int, error) {
+	n := min(limit, m.expired)
+	m.expired -= n
+	m.batches++
+	return n, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &backlogSnippetModel{expired: 25}
+	app.snippets = snippets
+
+	app.purgeExpired(context.Background(), 10)
+
+	// Two full batches of 10 and a final batch of 5.
+	assert.Equal(t, snippets.expired, 0)
+	assert.Equal(t, snippets.batches, 3)
+
+	// Nothing is deleted once the context has been cancelled.
+	snippets = &backlogSnippetModel{expired: 25}
+	app.snippets = snippets
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	app.purgeExpired(ctx, 10)
+
+	assert.Equal(t, snippets.batches, 0)
+}
+
+// Define a panickySnippetModel type whose PurgeExpired() method panics the
+// first time it's called, and reports every call on a channel.
+type panickySnippetModel struct {
+	mocks.SnippetModel
+	calls chan int
+	n     int
+}
+
+func (m *panickySnippetModel) PurgeExpired(limit int) (int, error) {
+	m.n++
+
+	// Don't block the purge goroutine once the test has stopped listening.
+	select {
+	case m.calls <- m.n:
+	default:
+	}
+
+	if m.n == 1 {
+		panic("oops! something went wrong")
+	}
+
+	return 0, nil
+}
+
+func TestPurgeRecovers(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &panickySnippetModel{calls: make(chan int, 10)}
+	app.snippets = snippets
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app.wg.Add(1)
+	go app.purge(ctx, 10*time.Millisecond, 10)
+
+	// After the panic in the first round, the purge carries on with the next
+	// tick.
+	for want := 1; want <= 2; want++ {
+		select {
+		case n := <-snippets.calls:
+			assert.Equal(t, n, want)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("purge() stopped after %d rounds", want-1)
+		}
+	}
+
+	cancel()
+	app.wg.Wait()
+}
+
+func TestPurgeStops(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	app.wg.Add(1)
+	go app.purge(ctx, time.Hour, 10)
+
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("purge() didn't stop after the context was cancelled")
+	}
+}
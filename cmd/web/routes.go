@@ -6,6 +6,7 @@ import (
 	//"wakisa.com/ui"
 
 	"github.com/justinas/alice"
+	"wakisa.com/internal/models"
 	"wakisa.com/ui"
 )
 
@@ -23,6 +24,11 @@ func (app *application) routes() http.Handler {
 	// file will be served (so long as it exists).
 	mux.Handle("GET /static/", http.FileServerFS(ui.Files))
 
+	// The stylesheet for syntax highlighting is generated from the same
+	// chroma style that's used to highlight snippets, so it's served by a
+	// handler rather than from the embedded filesystem.
+	mux.HandleFunc("GET /static/css/highlight.css", app.highlightStylesheet)
+
 	// Add a new GET /ping route.
 	mux.HandleFunc("GET /ping", ping)
 
@@ -36,7 +42,16 @@ func (app *application) routes() http.Handler {
 	// need to switch to registering the route using the mux.Handle() method.
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
 	mux.Handle("GET /about", dynamic.ThenFunc(app.about))
-	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("GET /snippet/view/{slug}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("GET /snippet/view/{slug}/history", dynamic.ThenFunc(app.snippetHistory))
+	mux.Handle("GET /snippet/view/{slug}/diff", dynamic.ThenFunc(app.snippetDiff))
+	mux.Handle("GET /snippet/raw/{slug}", dynamic.ThenFunc(app.snippetRaw))
+	mux.Handle("GET /snippet/download/{slug}", dynamic.ThenFunc(app.snippetDownload))
+	mux.Handle("GET /snippet/export/{slug}/{format}", dynamic.ThenFunc(app.snippetExport))
+	mux.Handle("POST /snippet/unlock/{slug}", dynamic.ThenFunc(app.snippetUnlockPost))
+	mux.Handle("POST /snippet/reveal/{slug}", dynamic.ThenFunc(app.snippetRevealPost))
+	mux.Handle("GET /snippet/search", dynamic.ThenFunc(app.snippetSearch))
+	mux.Handle("GET /tag/{name}", dynamic.ThenFunc(app.tagView))
 
 	// Add the five new routes, all of which use our 'dynamic' middleware chain.
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
@@ -50,7 +65,31 @@ func (app *application) routes() http.Handler {
 
 	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
 	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	mux.Handle("GET /snippet/fork/{slug}", protected.ThenFunc(app.snippetFork))
+	mux.Handle("GET /snippet/edit/{slug}", protected.ThenFunc(app.snippetEdit))
+	mux.Handle("POST /snippet/edit/{slug}", protected.ThenFunc(app.snippetEditPost))
+	mux.Handle("POST /snippet/delete/{slug}", protected.ThenFunc(app.snippetDeletePost))
 	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /user/snippets", protected.ThenFunc(app.userSnippets))
+	mux.Handle("GET /user/trash", protected.ThenFunc(app.userTrash))
+	mux.Handle("POST /user/trash/restore/{id}", protected.ThenFunc(app.userTrashRestorePost))
+	mux.Handle("GET /account/view", protected.ThenFunc(app.accountView))
+	mux.Handle("POST /account/tokens/create", protected.ThenFunc(app.accountTokenCreatePost))
+	mux.Handle("POST /account/tokens/revoke/{id}", protected.ThenFunc(app.accountTokenRevokePost))
+
+	// The JSON API gets its own middleware chain. It deliberately doesn't
+	// include the session manager or nosurf: API clients authenticate with
+	// an Authorization header rather than a cookie, so there's no ambient
+	// credential for a cross-site request to abuse. The catch-all /api/ route
+	// makes sure unknown API paths get a JSON 404 rather than plain text.
+	api := alice.New(app.authenticateToken, app.apiAuthenticate)
+	apiRead := api.Append(app.apiRequireScope(models.ScopeSnippetsRead))
+	apiWrite := api.Append(app.apiRequireAuthentication, app.apiRequireScope(models.ScopeSnippetsWrite))
+
+	mux.Handle("GET /api/v1/snippets", apiRead.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{slug}", apiRead.ThenFunc(app.apiSnippetView))
+	mux.Handle("POST /api/v1/snippets", apiWrite.ThenFunc(app.apiSnippetCreate))
+	mux.Handle("/api/", api.ThenFunc(app.apiNotFound))
 
 	// Create a middleware chain containing our 'standard' middleware
 	// which will be used for every request our application receives.
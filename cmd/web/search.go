@@ -0,0 +1,105 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MySQL ignores words shorter than three characters in FULLTEXT searches
+// (innodb_ft_min_token_size), so there's no point highlighting them either.
+const minSearchTermLength = 3
+
+// The length of the excerpt of a snippet's content shown in search results.
+const excerptLength = 200
+
+// The searchTerms() function splits a search query into the words that we
+// highlight in the results, dropping punctuation and very short words.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	for _, w := range words {
+		if utf8.RuneCountInString(w) >= minSearchTermLength {
+			terms = append(terms, w)
+		}
+	}
+
+	return terms
+}
+
+// The searchPattern() function returns a case-insensitive regular expression
+// which matches the start of any of the words in a search query, or nil if
+// there's nothing worth matching.
+func searchPattern(query string) *regexp.Regexp {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	for i, t := range terms {
+		terms[i] = regexp.QuoteMeta(t)
+	}
+
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(terms, "|") + `)`)
+}
+
+// The excerpt() template function returns a short extract of a snippet's
+// content for the search results, centred on the first match of the query
+// if there is one.
+func excerpt(content, query string) string {
+	runes := []rune(content)
+	if len(runes) <= excerptLength {
+		return content
+	}
+
+	start := 0
+	if rx := searchPattern(query); rx != nil {
+		if loc := rx.FindStringIndex(content); loc != nil {
+			// Convert the byte offset of the match into a rune offset, and
+			// leave a little context before it.
+			start = max(utf8.RuneCountInString(content[:loc[0]])-excerptLength/4, 0)
+		}
+	}
+	end := min(start+excerptLength, len(runes))
+	start = max(end-excerptLength, 0)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(string(runes[start:end]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+// The markTerms() template function wraps every word of the search query
+// found in text in a <mark> element. Everything else is HTML escaped, so the
+// result is safe to return as template.HTML.
+func markTerms(text, query string) template.HTML {
+	rx := searchPattern(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// The serve() method starts the HTTPS server and runs it until either it
+// fails, or the context is cancelled (which main() arranges to happen when
+// the application receives a SIGINT or SIGTERM signal). In the second case it
+// shuts the server down gracefully: it stops accepting new connections, and
+// gives the requests which are already in flight up to shutdownTimeout to
+// finish. A clean shutdown returns nil.
+func (app *application) serve(ctx context.Context, srv *http.Server, certFile, keyFile string, shutdownTimeout time.Duration) error {
+	serverErr := make(chan error, 1)
+
+	go func() {
+		serverErr <- srv.ListenAndServeTLS(certFile, keyFile)
+	}()
+
+	select {
+	case err := <-serverErr:
+		// ListenAndServeTLS() always returns a non-nil error. If we get here,
+		// it's because the server couldn't start or stopped unexpectedly.
+		return err
+	case <-ctx.Done():
+	}
+
+	app.logger.Info("shutting down server", "timeout", shutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Shutdown() makes ListenAndServeTLS() return http.ErrServerClosed
+	// straight away, and then waits for the in-flight requests. If they don't
+	// finish before the timeout it returns context.DeadlineExceeded.
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+
+	err = <-serverErr
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wakisa.com/internal/assert"
+)
+
+// The writeTestCert() helper writes a self-signed certificate for localhost,
+// and its private key, to a temporary directory and returns their paths.
+func writeTestCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+// The freeAddr() helper returns a local address with a port that nothing is
+// listening on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	app := newTestApplication(t)
+	certFile, keyFile := writeTestCert(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	// The handler doesn't finish until we say so, so the request is still in
+	// flight when the shutdown starts.
+	srv := &http.Server{
+		Addr: freeAddr(t),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("OK"))
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(ctx, srv, certFile, keyFile, 5*time.Second)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	respStatus := make(chan int, 1)
+	go func() {
+		// The server might not be listening yet, so keep trying for a while.
+		for range 50 {
+			rs, err := client.Get("https://" + srv.Addr)
+			if err == nil {
+				rs.Body.Close()
+				respStatus <- rs.StatusCode
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		respStatus <- 0
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request never reached the server")
+	}
+
+	// Signal the shutdown while the request is in flight, and then let the
+	// request finish. It should still get its response.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, <-respStatus, http.StatusOK)
+
+	select {
+	case err := <-serveErr:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve() didn't return after the shutdown")
+	}
+}
+
+func TestServeFailure(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Missing certificate files stop the server from starting at all.
+	srv := &http.Server{Addr: freeAddr(t)}
+
+	err := app.serve(context.Background(), srv, "missing-cert.pem", "missing-key.pem", time.Second)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"time"
 
+	"wakisa.com/internal/diff"
 	"wakisa.com/internal/models"
 	"wakisa.com/ui"
 )
@@ -49,6 +51,15 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		cache[name] = ts
 	}
 
+	// The standalone HTML export doesn't use the base layout, because it has
+	// to work as a file on its own, so it's parsed by itself.
+	ts, err := template.New("export.tmpl").Funcs(functions).ParseFS(ui.Files, "html/export.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	cache["export.tmpl"] = ts
+
 	// Return the map
 	return cache, nil
 }
@@ -65,22 +76,50 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// The contains function reports whether a string is in a slice, which the
+// templates use to re-check checkboxes like the token scopes.
+func contains(values []string, value string) bool {
+	return slices.Contains(values, value)
+}
+
 // Initialize a template.FunctMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":       humanDate,
+	"contains":        contains,
+	"highlight":       highlight,
+	"markdown":        markdown,
+	"excerpt":         excerpt,
+	"markTerms":       markTerms,
+	"highlightStyles": highlightStyles,
 }
 
 // Define a templateData type to act as the holding structure for
 // any dynamic data that we want to pass to our HTML templates.
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             models.Snippet
+	Snippets            []models.Snippet
+	Parent              *models.Snippet
+	Forks               []models.Snippet
+	Page                models.Page
+	Tag                 string
+	TagCounts           []models.TagCount
+	Revisions           []models.Revision
+	FromRevision        models.Revision
+	ToRevision          models.Revision
+	Diff                []diff.Line
+	TrashRetentionDays  int
+	User                models.User
+	Tokens              []models.Token
+	NewToken            string
+	Scopes              []string
+	Languages           []language
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
 }
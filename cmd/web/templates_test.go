@@ -1,10 +1,12 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"wakisa.com/internal/assert"
+	"wakisa.com/internal/models"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -50,3 +52,226 @@ func TestHumanDate(t *testing.T) {
 	}
 
 }
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string
+	}{
+		{
+			name:     "Go",
+			content:  "func main() {}",
+			language: "go",
+			want:     `<span class="kd">func</span>`,
+		},
+		{
+			name:     "Plain text is escaped",
+			content:  "<script>alert(1)</script>",
+			language: "plaintext",
+			want:     "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name:     "Unknown language",
+			content:  "<b>bold</b>",
+			language: "klingon",
+			want:     "&lt;b&gt;bold&lt;/b&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := highlight(tt.content, tt.language)
+
+			assert.NilError(t, err)
+			assert.StringContains(t, string(html), tt.want)
+
+			// Inline styles would be blocked by our Content-Security-Policy,
+			// so the highlighted HTML must only use classes.
+			if strings.Contains(string(html), "style=") {
+				t.Errorf("highlighted HTML contains an inline style: %q", html)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantNot string
+	}{
+		{
+			name:   "Heading",
+			source: "# Runbook",
+			want:   "<h1>Runbook</h1>",
+		},
+		{
+			name:   "Table",
+			source: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:   "<td>1</td>",
+		},
+		{
+			name:    "Script",
+			source:  "<script>alert(1)</script>",
+			wantNot: "<script",
+		},
+		{
+			name:    "Event handler",
+			source:  `<img src="https://example.com/a.png" onerror="alert(1)">`,
+			want:    `<img src="https://example.com/a.png">`,
+			wantNot: "onerror",
+		},
+		{
+			name:    "JavaScript link",
+			source:  "[click](javascript:alert(1))",
+			wantNot: "javascript:",
+		},
+		{
+			name:   "HTTPS link",
+			source: "[docs](https://example.com)",
+			want:   `<a href="https://example.com" rel="nofollow">docs</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := markdown(tt.source)
+			assert.NilError(t, err)
+
+			if tt.want != "" {
+				assert.StringContains(t, string(html), tt.want)
+			}
+
+			if tt.wantNot != "" && strings.Contains(string(html), tt.wantNot) {
+				t.Errorf("got %q; should not contain %q", html, tt.wantNot)
+			}
+		})
+	}
+}
+
+func TestMarkTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Single term",
+			text:  "An old silent pond",
+			query: "silent",
+			want:  "An old <mark>silent</mark> pond",
+		},
+		{
+			name:  "Case insensitive",
+			text:  "An old silent pond",
+			query: "POND old",
+			want:  "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:  "Short terms ignored",
+			text:  "An old silent pond",
+			query: "an",
+			want:  "An old silent pond",
+		},
+		{
+			name:  "Escaped",
+			text:  "<script>alert(1)</script>",
+			query: "alert",
+			want:  "&lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;",
+		},
+		{
+			name:  "Regexp characters",
+			text:  "a.*b",
+			query: ".*",
+			want:  "a.*b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(markTerms(tt.text, tt.query)), tt.want)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	short := "An old silent pond"
+	assert.Equal(t, excerpt(short, "pond"), short)
+
+	long := strings.Repeat("x ", 200) + "frog " + strings.Repeat("y ", 200)
+	got := excerpt(long, "frog")
+
+	assert.StringContains(t, got, "frog")
+	assert.Equal(t, strings.HasPrefix(got, "…"), true)
+	assert.Equal(t, strings.HasSuffix(got, "…"), true)
+}
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{
+			name:  "Simple",
+			title: "Restarting nginx",
+			want:  "restarting-nginx.sh",
+		},
+		{
+			name:  "Punctuation",
+			title: `  "Quotes"; and ../slashes/ `,
+			want:  "quotes-and-slashes.sh",
+		},
+		{
+			name:  "Long",
+			title: strings.Repeat("abcdefghi ", 10),
+			want:  "abcdefghi-abcdefghi-abcdefghi-abcdefghi-abcdefghi-abcdefghi.sh",
+		},
+		{
+			name:  "Nothing usable",
+			title: "日本語",
+			want:  "snippet-silentpond.sh",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := models.Snippet{Slug: "silentpond", Title: tt.title}
+			assert.Equal(t, snippetFilename(s, ".sh"), tt.want)
+		})
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet models.Snippet
+		want    string
+	}{
+		{
+			name:    "Code",
+			snippet: models.Snippet{Title: "Hello", Content: "fmt.Println(1)\n", Language: "go", Tags: []string{"go", "demo"}},
+			want:    "# Hello\n\n```go\nfmt.Println(1)\n```\n\nTags: go, demo\n",
+		},
+		{
+			name:    "Backticks in content",
+			snippet: models.Snippet{Title: "Fences", Content: "```\nx\n```", Language: "plaintext"},
+			want:    "# Fences\n\n````\n```\nx\n```\n````\n",
+		},
+		{
+			name:    "Markdown",
+			snippet: models.Snippet{Title: "Notes", Content: "*hi*", Language: "markdown"},
+			want:    "# Notes\n\n*hi*\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(exportMarkdown(tt.snippet)), tt.want)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Define an attemptLimiter type to throttle repeated failed attempts at
+// something, such as guessing the password for a protected snippet. Each key
+// gets a fixed window: once it has failed max times within the window, it's
+// blocked until the window runs out. The state is only kept in memory, so it
+// is reset whenever the application restarts.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[string]attempts
+}
+
+type attempts struct {
+	count int
+	start time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]attempts),
+	}
+}
+
+// The allow() method returns false if the key has used up its failed
+// attempts for the current window.
+func (l *attemptLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok || time.Since(a.start) > l.window {
+		return true
+	}
+
+	return a.count < l.max
+}
+
+// The fail() method records a failed attempt for the key. It also clears out
+// any windows which have run out, so that the map doesn't grow forever.
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	for k, a := range l.attempts {
+		if now.Sub(a.start) > l.window {
+			delete(l.attempts, k)
+		}
+	}
+
+	a, ok := l.attempts[key]
+	if !ok {
+		a = attempts{start: now}
+	}
+	a.count++
+
+	l.attempts[key] = a
+}
+
+// The reset() method forgets the failed attempts for the key, once it has
+// succeeded.
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// The clientIP() helper returns the IP address of the client which made the
+// request, without the port number.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,175 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Op describes what happened to a line between the old and new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// String() returns a lower-case name for the operation, which the templates
+// use as part of a CSS class name.
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Line is a single line in a diff. OldNumber and NewNumber are the 1-based
+// line numbers in the old and new text, and are 0 when the line doesn't
+// appear on that side (i.e. for inserted and deleted lines respectively).
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// maxCells caps the size of the table used to find the longest common
+// subsequence. If the changed region of two texts is bigger than this, we
+// give up on finding the minimal diff and report the whole region as
+// replaced, rather than using an unbounded amount of memory.
+const maxCells = 1 << 22
+
+// Lines() returns a line-by-line diff which turns the text a into b.
+func Lines(a, b string) []Line {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// Lines which are the same at the start and end of both texts don't need
+	// to go through the LCS table, which keeps it small for the common case
+	// of a few lines changing in the middle of a snippet.
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	oldNumber, newNumber := 1, 1
+
+	emit := func(op Op, text string) {
+		line := Line{Op: op, Text: text}
+		if op != Insert {
+			line.OldNumber = oldNumber
+			oldNumber++
+		}
+		if op != Delete {
+			line.NewNumber = newNumber
+			newNumber++
+		}
+		lines = append(lines, line)
+	}
+
+	for _, text := range x[:prefix] {
+		emit(Equal, text)
+	}
+
+	mx := x[prefix : len(x)-suffix]
+	my := y[prefix : len(y)-suffix]
+
+	for _, op := range middle(mx, my) {
+		switch op {
+		case Equal:
+			emit(Equal, mx[0])
+			mx, my = mx[1:], my[1:]
+		case Delete:
+			emit(Delete, mx[0])
+			mx = mx[1:]
+		case Insert:
+			emit(Insert, my[0])
+			my = my[1:]
+		}
+	}
+
+	for _, text := range x[len(x)-suffix:] {
+		emit(Equal, text)
+	}
+
+	return lines
+}
+
+// middle() returns the sequence of operations which turns x into y, using
+// the classic dynamic programming solution to the longest common subsequence
+// problem.
+func middle(x, y []string) []Op {
+	n, m := len(x), len(y)
+
+	var ops []Op
+
+	if (n+1)*(m+1) > maxCells {
+		for range x {
+			ops = append(ops, Delete)
+		}
+		for range y {
+			ops = append(ops, Insert)
+		}
+		return ops
+	}
+
+	// lcs[i*(m+1)+j] holds the length of the longest common subsequence of
+	// x[i:] and y[j:].
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case x[i] == y[j]:
+			ops = append(ops, Equal)
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			ops = append(ops, Delete)
+			i++
+		default:
+			ops = append(ops, Insert)
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, Delete)
+	}
+	for ; j < m; j++ {
+		ops = append(ops, Insert)
+	}
+
+	return ops
+}
+
+// splitLines() splits a text into lines, normalizing Windows line endings
+// (which browsers send for textarea content) so that they don't show up as
+// changes.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"wakisa.com/internal/assert"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+		{
+			name: "Empty to text",
+			a:    "",
+			b:    "one\ntwo",
+			want: "+one\n+two\n",
+		},
+		{
+			name: "Text to empty",
+			a:    "one\ntwo",
+			b:    "",
+			want: "-one\n-two\n",
+		},
+		{
+			name: "Changed middle line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: " one\n-two\n+2\n three\n",
+		},
+		{
+			name: "Inserted and deleted lines",
+			a:    "a\nb\nc\nd",
+			b:    "a\nc\nd\ne",
+			want: " a\n-b\n c\n d\n+e\n",
+		},
+		{
+			name: "Windows line endings",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+
+			for _, line := range Lines(tt.a, tt.b) {
+				switch line.Op {
+				case Equal:
+					sb.WriteString(" ")
+				case Insert:
+					sb.WriteString("+")
+				case Delete:
+					sb.WriteString("-")
+				}
+				sb.WriteString(line.Text + "\n")
+			}
+
+			assert.Equal(t, sb.String(), tt.want)
+		})
+	}
+}
+
+func TestLinesNumbers(t *testing.T) {
+	lines := Lines("a\nb\nc", "a\nx\nc")
+
+	// The deleted line only has an old line number, and the inserted line
+	// only has a new one.
+	assert.Equal(t, len(lines), 4)
+	assert.Equal(t, lines[1], Line{Op: Delete, Text: "b", OldNumber: 2})
+	assert.Equal(t, lines[2], Line{Op: Insert, Text: "x", NewNumber: 2})
+	assert.Equal(t, lines[3], Line{Op: Equal, Text: "c", OldNumber: 3, NewNumber: 3})
+}
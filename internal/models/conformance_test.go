@@ -0,0 +1,563 @@
+package models
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wakisa.com/internal/assert"
+)
+
+// The conformance tests check that every storage backend behaves in the same
+// way, by running the same tests against each of them. A new backend only
+// needs an entry in testBackends to be held to the same standard.
+
+// Define a testModels type to hold the models for one backend.
+type testModels struct {
+	snippets SnippetModelInterface
+	users    UserModelInterface
+	tokens   TokenModelInterface
+}
+
+// The backends the conformance tests run against. Each open function returns
+// the models for a fresh database which contains a single user, Alice Jones,
+// with the ID 1. The MySQL and PostgreSQL backends need a test database to be
+// set up (see newTestDB() and newTestPostgres()); the SQLite and in-memory
+// ones don't need anything.
+var testBackends = []struct {
+	name string
+	open func(t *testing.T) testModels
+}{
+	{
+		name: "MySQL",
+		open: func(t *testing.T) testModels {
+			db := newTestDB(t)
+			return testModels{&SnippetModel{DB: db}, &UserModel{DB: db}, &TokenModel{DB: db}}
+		},
+	},
+	{
+		name: "SQLite",
+		open: func(t *testing.T) testModels {
+			db := newTestSQLite(t)
+			return testModels{&SQLiteSnippetModel{DB: db}, &SQLiteUserModel{DB: db}, &SQLiteTokenModel{DB: db}}
+		},
+	},
+	{
+		name: "Postgres",
+		open: func(t *testing.T) testModels {
+			db := newTestPostgres(t)
+			return testModels{&PostgresSnippetModel{DB: db}, &PostgresUserModel{DB: db}, &PostgresTokenModel{DB: db}}
+		},
+	},
+	{
+		name: "Memory",
+		open: func(t *testing.T) testModels {
+			db := newTestMemoryDB(t)
+			return testModels{&MemorySnippetModel{DB: db}, &MemoryUserModel{DB: db}, &MemoryTokenModel{DB: db}}
+		},
+	},
+}
+
+// The forEachBackend() helper runs a test as a sub-test for each backend,
+// with a fresh database every time.
+func forEachBackend(t *testing.T, test func(t *testing.T, m testModels)) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+func TestConformanceUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		err := m.users.Insert("Bob Smith", "bob@example.com", "pa$$word")
+		assert.NilError(t, err)
+
+		id, err := m.users.Authenticate("bob@example.com", "pa$$word")
+		assert.NilError(t, err)
+
+		_, err = m.users.Authenticate("bob@example.com", "wrong")
+		assert.Equal(t, err, ErrInvalidCredentials)
+
+		_, err = m.users.Authenticate("carol@example.com", "pa$$word")
+		assert.Equal(t, err, ErrInvalidCredentials)
+
+		err = m.users.Insert("Alice Again", "alice@example.com", "pa$$word")
+		assert.Equal(t, err, ErrDuplicateEmail)
+
+		exists, err := m.users.Exists(id)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+
+		exists, err = m.users.Exists(1000)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, false)
+
+		user, err := m.users.Get(1)
+		assert.NilError(t, err)
+		assert.Equal(t, user.Email, "alice@example.com")
+		assert.Equal(t, user.Created.IsZero(), false)
+
+		_, err = m.users.Get(1000)
+		assert.Equal(t, err, ErrNoRecord)
+	})
+}
+
+func TestConformanceTokens(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		plaintext, err := m.tokens.Insert(1, "CLI", []string{ScopeSnippetsRead}, 7)
+		assert.NilError(t, err)
+
+		token, err := m.tokens.GetForToken(plaintext)
+		assert.NilError(t, err)
+		assert.Equal(t, token.UserID, 1)
+		assert.Equal(t, token.HasScope(ScopeSnippetsRead), true)
+		assert.Equal(t, token.HasScope(ScopeSnippetsWrite), false)
+		assert.Equal(t, token.Expires.After(time.Now()), true)
+
+		_, err = m.tokens.GetForToken("NOTATOKEN")
+		assert.Equal(t, err, ErrNoRecord)
+
+		// A token with an expires value of 0 never expires.
+		_, err = m.tokens.Insert(1, "Forever", []string{ScopeSnippetsRead, ScopeSnippetsWrite}, 0)
+		assert.NilError(t, err)
+
+		tokens, err := m.tokens.ForUser(1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(tokens), 2)
+		assert.Equal(t, tokens[0].Name, "Forever")
+		assert.Equal(t, tokens[0].Expires.IsZero(), true)
+		assert.Equal(t, tokens[0].LastUsed.IsZero(), true)
+		assert.Equal(t, tokens[1].LastUsed.IsZero(), false)
+
+		// Users can only revoke their own tokens.
+		assert.Equal(t, m.tokens.Revoke(2, token.ID), ErrNoRecord)
+		assert.NilError(t, m.tokens.Revoke(1, token.ID))
+		assert.Equal(t, m.tokens.Revoke(1, token.ID), ErrNoRecord)
+
+		_, err = m.tokens.GetForToken(plaintext)
+		assert.Equal(t, err, ErrNoRecord)
+	})
+}
+
+func TestConformanceSnippets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		params := SnippetParams{Title: "Restarting nginx", Content: "sudo systemctl restart nginx", Language: "bash", Tags: []string{"nginx", "ops"}, Visibility: VisibilityPublic, Expires: 7}
+
+		id, slug, err := m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		assert.Equal(t, SlugRX.MatchString(slug), true)
+
+		s, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+		assert.Equal(t, s.ID, id)
+		assert.Equal(t, s.UserID, 1)
+		assert.Equal(t, s.Title, "Restarting nginx")
+		assert.Equal(t, strings.Join(s.Tags, ","), "nginx,ops")
+		assert.Equal(t, s.IsProtected(), false)
+		assert.Equal(t, s.IsViewLimited(), false)
+		assert.Equal(t, s.Expires.After(time.Now().AddDate(0, 0, 6)), true)
+
+		got, err := m.snippets.Get(s.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, got.Slug, slug)
+
+		// Vanity slugs have to be unique.
+		params.Slug = "nginx"
+		_, _, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		_, _, err = m.snippets.Insert(1, params)
+		assert.Equal(t, err, ErrDuplicateSlug)
+		params.Slug = ""
+
+		vanity, err := m.snippets.GetBySlug("nginx")
+		assert.NilError(t, err)
+
+		// Expired snippets can't be fetched.
+		expired := params
+		expired.Expires = -1
+		_, slug, err = m.snippets.Insert(1, expired)
+		assert.NilError(t, err)
+		_, err = m.snippets.GetBySlug(slug)
+		assert.Equal(t, err, ErrNoRecord)
+
+		// But their owner can still find them, so they can be deleted.
+		owned, err := m.snippets.GetOwned(1, slug)
+		assert.NilError(t, err)
+		assert.Equal(t, owned.IsExpired(), true)
+
+		_, err = m.snippets.GetOwned(2, slug)
+		assert.Equal(t, err, ErrNoRecord)
+
+		_, err = m.snippets.Get(1000)
+		assert.Equal(t, err, ErrNoRecord)
+
+		// Only the live, public snippets are listed.
+		unlisted := params
+		unlisted.Visibility = VisibilityUnlisted
+		_, _, err = m.snippets.Insert(1, unlisted)
+		assert.NilError(t, err)
+
+		page, err := m.snippets.Latest(Cursor{Limit: 1})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 1)
+		assert.Equal(t, page.Snippets[0].ID, vanity.ID)
+		assert.Equal(t, page.Next, vanity.ID)
+		assert.Equal(t, page.Prev, 0)
+
+		page, err = m.snippets.Latest(Cursor{Before: page.Next})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 1)
+		assert.Equal(t, page.Snippets[0].ID, s.ID)
+		assert.Equal(t, page.Next, 0)
+		assert.Equal(t, page.Prev, s.ID)
+
+		page, err = m.snippets.Latest(Cursor{After: page.Prev})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 1)
+		assert.Equal(t, page.Snippets[0].ID, vanity.ID)
+
+		page, err = m.snippets.Search("restart NGINX", Cursor{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 2)
+
+		page, err = m.snippets.Search("apache", Cursor{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 0)
+
+		page, err = m.snippets.ForTag("ops", Cursor{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 2)
+
+		counts, err := m.snippets.TagCounts(10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(counts), 2)
+		assert.Equal(t, counts[0], TagCount{Name: "nginx", Count: 2})
+
+		// Updating a snippet records a new revision and replaces its tags.
+		params.Content = "sudo systemctl reload nginx"
+		params.Tags = []string{"web"}
+		assert.NilError(t, m.snippets.Update(s.ID, params))
+
+		s, err = m.snippets.Get(s.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Content, "sudo systemctl reload nginx")
+		assert.Equal(t, strings.Join(s.Tags, ","), "web")
+
+		revisions, err := m.snippets.Revisions(s.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(revisions), 2)
+		assert.Equal(t, revisions[0].Version, 2)
+
+		rev, err := m.snippets.Revision(s.ID, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, rev.Content, "sudo systemctl restart nginx")
+
+		_, err = m.snippets.Revision(s.ID, 3)
+		assert.Equal(t, err, ErrNoRecord)
+
+		// Only live snippets can be updated.
+		assert.Equal(t, m.snippets.Update(s.ID+1000, params), ErrNoRecord)
+
+		// Deleted snippets go to the trash, and can be restored from it.
+		assert.NilError(t, m.snippets.Delete(s.ID))
+
+		_, err = m.snippets.Get(s.ID)
+		assert.Equal(t, err, ErrNoRecord)
+		assert.Equal(t, m.snippets.Update(s.ID, params), ErrNoRecord)
+
+		trash, err := m.snippets.Trash(1, time.Hour)
+		assert.NilError(t, err)
+		assert.Equal(t, len(trash), 1)
+		assert.Equal(t, trash[0].DeletedAt.IsZero(), false)
+
+		assert.Equal(t, m.snippets.Restore(2, s.ID, time.Hour), ErrNoRecord)
+		assert.NilError(t, m.snippets.Restore(1, s.ID, time.Hour))
+		assert.Equal(t, m.snippets.Restore(1, s.ID, time.Hour), ErrNoRecord)
+
+		// Once the retention window has passed, they're purged.
+		assert.NilError(t, m.snippets.Delete(s.ID))
+
+		n, err := m.snippets.Purge(-time.Minute)
+		assert.NilError(t, err)
+		assert.Equal(t, n, 1)
+
+		// The user's own listing includes expired snippets until they're
+		// purged too.
+		snippets, err := m.snippets.ForUser(1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), 3)
+
+		n, err = m.snippets.PurgeExpired(10)
+		assert.NilError(t, err)
+		assert.Equal(t, n, 1)
+
+		snippets, err = m.snippets.ForUser(1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), 2)
+	})
+}
+
+func TestConformanceSnippetPasswords(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		_, slug, err := m.snippets.Insert(1, SnippetParams{Password: "open sesame", Title: "Locked", Content: "Secret", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7})
+		assert.NilError(t, err)
+
+		s, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+		assert.Equal(t, s.IsProtected(), true)
+
+		assert.NilError(t, m.snippets.VerifyPassword(s.ID, "open sesame"))
+		assert.Equal(t, m.snippets.VerifyPassword(s.ID, "wrong password"), ErrInvalidCredentials)
+		assert.Equal(t, m.snippets.VerifyPassword(1000, "open sesame"), ErrInvalidCredentials)
+
+		// Protected snippets never turn up in search results.
+		page, err := m.snippets.Search("secret", Cursor{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 0)
+	})
+}
+
+func TestConformanceSnippetViewLimits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		_, slug, err := m.snippets.Insert(1, SnippetParams{MaxViews: 2, Title: "Title", Content: "Content", Language: "plaintext", Tags: []string{"limited"}, Visibility: VisibilityPublic, Expires: 7})
+		assert.NilError(t, err)
+
+		s, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+		assert.Equal(t, s.ViewsLeft, 2)
+
+		// View-limited snippets aren't listed anywhere.
+		page, err := m.snippets.Latest(Cursor{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page.Snippets), 0)
+
+		counts, err := m.snippets.TagCounts(10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(counts), 0)
+
+		s, err = m.snippets.Consume(s.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, s.ViewsLeft, 2)
+		assert.Equal(t, strings.Join(s.Tags, ","), "limited")
+
+		// Only one of several simultaneous readers gets the last view.
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := m.snippets.Consume(s.ID)
+				errs <- err
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		var consumed int
+		for err := range errs {
+			switch err {
+			case nil:
+				consumed++
+			case ErrNoRecord:
+			default:
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal(t, consumed, 1)
+
+		_, err = m.snippets.Get(s.ID)
+		assert.Equal(t, err, ErrNoRecord)
+	})
+}
+
+func TestConformanceUserAdmin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		err := m.users.Insert("Bob Smith", "bob@example.com", "pa$$word")
+		assert.NilError(t, err)
+
+		bob, err := m.users.GetByEmail("bob@example.com")
+		assert.NilError(t, err)
+		assert.Equal(t, bob.Name, "Bob Smith")
+		assert.Equal(t, bob.Disabled, false)
+
+		_, err = m.users.GetByEmail("carol@example.com")
+		assert.Equal(t, err, ErrNoRecord)
+
+		users, err := m.users.List()
+		assert.NilError(t, err)
+		assert.Equal(t, len(users), 2)
+		assert.Equal(t, users[0].Email, "alice@example.com")
+		assert.Equal(t, users[1].ID, bob.ID)
+
+		// A new password replaces the old one.
+		assert.NilError(t, m.users.SetPassword(bob.ID, "new pa$$word"))
+		_, err = m.users.Authenticate("bob@example.com", "pa$$word")
+		assert.Equal(t, err, ErrInvalidCredentials)
+		_, err = m.users.Authenticate("bob@example.com", "new pa$$word")
+		assert.NilError(t, err)
+
+		plaintext, err := m.tokens.Insert(bob.ID, "CLI", []string{ScopeSnippetsRead}, 0)
+		assert.NilError(t, err)
+
+		// A disabled user can't log in or use their tokens, and no longer
+		// counts as existing. Only the right password reveals that the
+		// account is disabled.
+		assert.NilError(t, m.users.SetDisabled(bob.ID, true))
+		assert.NilError(t, m.users.SetDisabled(bob.ID, true))
+
+		_, err = m.users.Authenticate("bob@example.com", "new pa$$word")
+		assert.Equal(t, err, ErrAccountDisabled)
+		_, err = m.users.Authenticate("bob@example.com", "wrong")
+		assert.Equal(t, err, ErrInvalidCredentials)
+
+		exists, err := m.users.Exists(bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, false)
+
+		_, err = m.tokens.GetForToken(plaintext)
+		assert.Equal(t, err, ErrNoRecord)
+
+		bob, err = m.users.Get(bob.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, bob.Disabled, true)
+
+		// Enabling the account again puts everything back.
+		assert.NilError(t, m.users.SetDisabled(bob.ID, false))
+
+		_, err = m.users.Authenticate("bob@example.com", "new pa$$word")
+		assert.NilError(t, err)
+		_, err = m.tokens.GetForToken(plaintext)
+		assert.NilError(t, err)
+
+		assert.Equal(t, m.users.SetPassword(1000, "pa$$word"), ErrNoRecord)
+		assert.Equal(t, m.users.SetDisabled(1000, true), ErrNoRecord)
+	})
+}
+
+func TestConformanceSnippetAdmin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		params := SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPrivate, Expires: 7}
+
+		_, slug, err := m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		live, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+
+		params.Expires = -1
+		_, _, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+
+		params.Slug = "trashed"
+		_, _, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+
+		// All() includes private and expired snippets, newest first, but not
+		// the ones in the trash.
+		all, err := m.snippets.All(10)
+		assert.NilError(t, err)
+		assert.Equal(t, len(all), 3)
+		trashed := all[0]
+		expired := all[1]
+		assert.Equal(t, expired.IsExpired(), true)
+		assert.Equal(t, all[2].ID, live.ID)
+
+		assert.NilError(t, m.snippets.Delete(trashed.ID))
+
+		all, err = m.snippets.All(1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(all), 1)
+		assert.Equal(t, all[0].ID, expired.ID)
+
+		// Extending a live snippet adds to its expiry, and extending an
+		// expired one counts from now, bringing it back.
+		assert.NilError(t, m.snippets.Extend(live.ID, 30))
+		s, err := m.snippets.Get(live.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Expires.After(time.Now().AddDate(0, 0, 36)), true)
+
+		assert.NilError(t, m.snippets.Extend(expired.ID, 1))
+		s, err = m.snippets.Get(expired.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Expires.After(time.Now()), true)
+		assert.Equal(t, s.Expires.Before(time.Now().AddDate(0, 0, 2)), true)
+
+		assert.Equal(t, m.snippets.Extend(trashed.ID, 1), ErrNoRecord)
+		assert.Equal(t, m.snippets.Extend(1000, 1), ErrNoRecord)
+
+		// Remove() deletes snippets for good, whether or not they're in the
+		// trash.
+		assert.NilError(t, m.snippets.Remove(live.ID))
+		assert.NilError(t, m.snippets.Remove(trashed.ID))
+		assert.Equal(t, m.snippets.Remove(live.ID), ErrNoRecord)
+
+		_, err = m.snippets.Get(live.ID)
+		assert.Equal(t, err, ErrNoRecord)
+
+		trash, err := m.snippets.Trash(1, time.Hour)
+		assert.NilError(t, err)
+		assert.Equal(t, len(trash), 0)
+	})
+}
+
+func TestConformanceSnippetForks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, m testModels) {
+		params := SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7}
+
+		_, slug, err := m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		parent, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+		assert.Equal(t, parent.ParentID, 0)
+
+		params.ParentID = parent.ID
+
+		_, slug, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		fork, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+		assert.Equal(t, fork.ParentID, parent.ID)
+
+		// Forks() includes forks of every visibility, newest first, but not
+		// the ones which have expired or are in the trash.
+		params.Visibility = VisibilityPrivate
+
+		_, slug, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		privateFork, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+
+		params.Expires = -1
+		_, _, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+
+		params.Expires = 7
+		_, slug, err = m.snippets.Insert(1, params)
+		assert.NilError(t, err)
+		trashed, err := m.snippets.GetBySlug(slug)
+		assert.NilError(t, err)
+		assert.NilError(t, m.snippets.Delete(trashed.ID))
+
+		forks, err := m.snippets.Forks(parent.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(forks), 2)
+		assert.Equal(t, forks[0].ID, privateFork.ID)
+		assert.Equal(t, forks[1].ID, fork.ID)
+
+		// A fork outlives its parent, still recording where it came from.
+		assert.NilError(t, m.snippets.Remove(parent.ID))
+
+		fork, err = m.snippets.Get(fork.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, fork.ParentID, parent.ID)
+
+		forks, err = m.snippets.Forks(fork.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(forks), 0)
+	})
+}
@@ -14,4 +14,17 @@ var (
 	// Add a new ErrDuplicateEmail error. We'll use this later if a user
 	// tries to signup with an email address that's already in use.
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// ErrAccountDisabled is returned by Authenticate() if the password is
+	// correct but an administrator has disabled the account.
+	ErrAccountDisabled = errors.New("models: account disabled")
+
+	// ErrDuplicateSlug is returned if a user asks for a vanity slug which
+	// another snippet already has.
+	ErrDuplicateSlug = errors.New("models: duplicate slug")
+
+	// ErrSchemaMismatch is returned by Migrator.Up() if the database already
+	// has tables which don't match the first migration, so it can't be
+	// brought under the control of migrations automatically.
+	ErrSchemaMismatch = errors.New("models: existing tables don't match the initial schema")
 )
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// The in-memory backend keeps everything in Go maps, so the application can
+// be run without any database at all -- handy for working on the UI. Unlike
+// the mocks, it's a real implementation: snippets expire, duplicate emails
+// are rejected and passwords are checked with bcrypt, exactly as they are by
+// the SQL backends. Everything is lost when the application stops.
+
+// Define a MemoryDB type to hold the data for the in-memory models. It plays
+// the same part as a *sql.DB does for the SQL models: create one with
+// NewMemoryDB() and share it between a MemorySnippetModel, MemoryUserModel
+// and MemoryTokenModel. A single mutex guards all the data, so it's safe for
+// concurrent use.
+type MemoryDB struct {
+	mu        sync.RWMutex
+	users     map[int]User
+	snippets  map[int]Snippet
+	revisions map[int][]Revision
+	tokens    map[int]Token
+	// The last ID handed out for each kind of record. Like an AUTO_INCREMENT
+	// column, IDs only ever go up and are never reused.
+	lastUserID     int
+	lastSnippetID  int
+	lastRevisionID int
+	lastTokenID    int
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		users:     make(map[int]User),
+		snippets:  make(map[int]Snippet),
+		revisions: make(map[int][]Revision),
+		tokens:    make(map[int]Token),
+	}
+}
+
+// Define a fixtures type to describe the JSON accepted by LoadFixtures().
+// Snippets name their owner by email address, and the other snippet fields
+// are the same as in SnippetParams.
+type fixtures struct {
+	Users []struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	} `json:"users"`
+	Snippets []struct {
+		User       string   `json:"user"`
+		Slug       string   `json:"slug"`
+		Password   string   `json:"password"`
+		MaxViews   int      `json:"max_views"`
+		Title      string   `json:"title"`
+		Content    string   `json:"content"`
+		Language   string   `json:"language"`
+		Tags       []string `json:"tags"`
+		Visibility string   `json:"visibility"`
+		Expires    int      `json:"expires"`
+	} `json:"snippets"`
+}
+
+// The LoadFixtures() method seeds the database with the users and snippets
+// in a JSON fixtures file, like this:
+//
+//	{
+//	  "users": [
+//	    {"name": "Alice Jones", "email": "alice@example.com", "password": "pa$$word"}
+//	  ],
+//	  "snippets": [
+//	    {"user": "alice@example.com", "title": "Hello", "content": "Hello, world!",
+//	     "language": "plaintext", "tags": ["greeting"], "visibility": "public", "expires": 365}
+//	  ]
+//	}
+//
+// Everything goes in through the models' Insert() methods, so passwords are
+// hashed and the same rules apply as for anything created through the UI.
+// Language and visibility default to plaintext and public.
+func (db *MemoryDB) LoadFixtures(r io.Reader) error {
+	var f fixtures
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&f)
+	if err != nil {
+		return fmt.Errorf("fixtures: %w", err)
+	}
+
+	users := &MemoryUserModel{DB: db}
+	snippets := &MemorySnippetModel{DB: db}
+
+	for _, u := range f.Users {
+		err = users.Insert(u.Name, u.Email, u.Password)
+		if err != nil {
+			return fmt.Errorf("fixtures: user %s: %w", u.Email, err)
+		}
+	}
+
+	for i, s := range f.Snippets {
+		userID, ok := db.userIDForEmail(s.User)
+		if !ok {
+			return fmt.Errorf("fixtures: snippet %d: no user with email %q", i+1, s.User)
+		}
+
+		p := SnippetParams{
+			Slug:       s.Slug,
+			Password:   s.Password,
+			MaxViews:   s.MaxViews,
+			Title:      s.Title,
+			Content:    s.Content,
+			Language:   s.Language,
+			Tags:       s.Tags,
+			Visibility: s.Visibility,
+			Expires:    s.Expires,
+		}
+		if p.Language == "" {
+			p.Language = "plaintext"
+		}
+		if p.Visibility == "" {
+			p.Visibility = VisibilityPublic
+		}
+
+		_, _, err = snippets.Insert(userID, p)
+		if err != nil {
+			return fmt.Errorf("fixtures: snippet %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// The userIDForEmail() method looks up a user's ID by their email address.
+func (db *MemoryDB) userIDForEmail(email string) (int, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, u := range db.users {
+		if u.Email == email {
+			return u.ID, true
+		}
+	}
+
+	return 0, false
+}
+
+// The cloneSnippet() function returns a copy of a snippet which doesn't share
+// any slices with the original, so that callers can't change the stored
+// snippet by accident (or race with somebody else changing it). As with the
+// SQL backends, a snippet without tags has an empty slice rather than nil.
+func cloneSnippet(s Snippet) Snippet {
+	s.Tags = slices.Clone(s.Tags)
+	if s.Tags == nil {
+		s.Tags = []string{}
+	}
+	s.HashedPassword = slices.Clone(s.HashedPassword)
+	return s
+}
+
+// errMemoryNoUser is returned when a snippet or token is created for a user
+// who doesn't exist, which the SQL backends reject with a foreign key error.
+var errMemoryNoUser = errors.New("models: no user with that ID")
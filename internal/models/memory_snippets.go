@@ -0,0 +1,530 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Define a MemorySnippetModel type which implements SnippetModelInterface on
+// top of a MemoryDB. It behaves exactly like the MySQL SnippetModel; see
+// there for what each method does.
+type MemorySnippetModel struct {
+	DB *MemoryDB
+}
+
+func (m *MemorySnippetModel) Insert(userID int, p SnippetParams) (int, string, error) {
+	// Hash the password before taking the lock, as bcrypt is deliberately
+	// slow.
+	hashedPassword, err := hashSnippetPassword(p.Password)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return insertWithSlug(p, func(p SnippetParams) (int, error) {
+		return m.insert(userID, p, hashedPassword)
+	})
+}
+
+func (m *MemorySnippetModel) insert(userID int, p SnippetParams, hashedPassword []byte) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.users[userID]; !ok {
+		return 0, errMemoryNoUser
+	}
+
+	// Slugs are unique across every snippet, including the ones which have
+	// expired or are in the trash, just like the unique key on the slug
+	// column.
+	for _, s := range m.DB.snippets {
+		if s.Slug == p.Slug {
+			return 0, ErrDuplicateSlug
+		}
+	}
+
+	now := time.Now().UTC()
+
+	m.DB.lastSnippetID++
+
+	s := Snippet{
+		ID:             m.DB.lastSnippetID,
+		Slug:           p.Slug,
+		UserID:         userID,
+		Title:          p.Title,
+		Content:        p.Content,
+		Language:       p.Language,
+		Tags:           memoryTags(p.Tags),
+		Visibility:     p.Visibility,
+		Created:        now,
+		Expires:        now.AddDate(0, 0, p.Expires),
+		HashedPassword: hashedPassword,
+		ViewsLeft:      p.MaxViews,
+		ParentID:       p.ParentID,
+	}
+
+	m.DB.snippets[s.ID] = s
+	m.addRevision(s.ID, p.Title, p.Content, now)
+
+	return s.ID, nil
+}
+
+// The memoryTags() function returns a copy of a snippet's tags in the order
+// the SQL backends return them, sorted by name.
+func memoryTags(tags []string) []string {
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// The addRevision() method records the next revision of a snippet. The
+// caller must hold the write lock.
+func (m *MemorySnippetModel) addRevision(snippetID int, title, content string, created time.Time) {
+	revisions := m.DB.revisions[snippetID]
+
+	m.DB.lastRevisionID++
+
+	m.DB.revisions[snippetID] = append(revisions, Revision{
+		ID:        m.DB.lastRevisionID,
+		SnippetID: snippetID,
+		Version:   len(revisions) + 1,
+		Title:     title,
+		Content:   content,
+		Created:   created,
+	})
+}
+
+// The isLive() function returns true if a snippet hasn't expired and isn't
+// in the trash, which is what the SQL backends check for with
+// "expires > UTC_TIMESTAMP() AND deleted_at IS NULL".
+func isLive(s Snippet, now time.Time) bool {
+	return s.Expires.After(now) && s.DeletedAt.IsZero()
+}
+
+// The isListed() function returns true if a snippet belongs in the public
+// listings: it's live, public and not view limited.
+func isListed(s Snippet, now time.Time) bool {
+	return isLive(s, now) && s.Visibility == VisibilityPublic && s.ViewsLeft == 0
+}
+
+func (m *MemorySnippetModel) Get(id int) (Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !isLive(s, time.Now()) {
+		return Snippet{}, ErrNoRecord
+	}
+
+	return cloneSnippet(s), nil
+}
+
+func (m *MemorySnippetModel) GetBySlug(slug string) (Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	now := time.Now()
+
+	for _, s := range m.DB.snippets {
+		if s.Slug == slug && isLive(s, now) {
+			return cloneSnippet(s), nil
+		}
+	}
+
+	return Snippet{}, ErrNoRecord
+}
+
+func (m *MemorySnippetModel) GetOwned(userID int, slug string) (Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, s := range m.DB.snippets {
+		if s.Slug == slug && s.UserID == userID && s.DeletedAt.IsZero() {
+			return cloneSnippet(s), nil
+		}
+	}
+
+	return Snippet{}, ErrNoRecord
+}
+
+func (m *MemorySnippetModel) VerifyPassword(id int, password string) error {
+	m.DB.mu.RLock()
+	s, ok := m.DB.snippets[id]
+	m.DB.mu.RUnlock()
+
+	if !ok || s.HashedPassword == nil {
+		return ErrInvalidCredentials
+	}
+
+	return compareSnippetPassword(s.HashedPassword, password)
+}
+
+// Holding the write lock for the whole of Consume() does the job of the
+// SELECT ... FOR UPDATE in the MySQL model: a second reader has to wait, and
+// then finds the snippet gone or with one view fewer.
+func (m *MemorySnippetModel) Consume(id int) (Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !isLive(s, time.Now()) {
+		return Snippet{}, ErrNoRecord
+	}
+
+	switch {
+	case s.ViewsLeft == 1:
+		m.remove(id)
+	case s.ViewsLeft > 1:
+		updated := s
+		updated.ViewsLeft--
+		m.DB.snippets[id] = updated
+	}
+
+	return cloneSnippet(s), nil
+}
+
+// The remove() method deletes a snippet for good, along with its revisions.
+// The caller must hold the write lock.
+func (m *MemorySnippetModel) remove(id int) {
+	delete(m.DB.snippets, id)
+	delete(m.DB.revisions, id)
+}
+
+func (m *MemorySnippetModel) Latest(c Cursor) (Page, error) {
+	return m.page(nil, c), nil
+}
+
+// As with the SQLite backend, a snippet matches a search if its title or
+// content contains every word in the query, ignoring case.
+func (m *MemorySnippetModel) Search(query string, c Cursor) (Page, error) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return Page{Limit: c.size()}, nil
+	}
+
+	return m.page(func(s Snippet) bool {
+		if s.HashedPassword != nil {
+			return false
+		}
+
+		text := strings.ToLower(s.Title + " " + s.Content)
+
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+
+		return true
+	}, c), nil
+}
+
+func (m *MemorySnippetModel) ForTag(tag string, c Cursor) (Page, error) {
+	return m.page(func(s Snippet) bool {
+		return slices.Contains(s.Tags, tag)
+	}, c), nil
+}
+
+// The page() helper is the in-memory version of SnippetModel.page(). The
+// optional filter picks out the snippets to include, on top of the ones
+// which are listed at all.
+func (m *MemorySnippetModel) page(filter func(Snippet) bool, c Cursor) Page {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	limit := c.size()
+	now := time.Now()
+
+	var snippets []Snippet
+
+	for _, s := range m.DB.snippets {
+		if !isListed(s, now) || (filter != nil && !filter(s)) {
+			continue
+		}
+
+		switch {
+		case c.After > 0 && s.ID <= c.After:
+			continue
+		case c.Before > 0 && s.ID >= c.Before:
+			continue
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	// Put the snippets in the order the SQL query would have walked them:
+	// ascending from the cursor when paging with After, and newest first
+	// otherwise. Then keep the one extra snippet which newPage() uses to
+	// tell whether there's another page.
+	if c.After > 0 {
+		slices.SortFunc(snippets, func(a, b Snippet) int { return a.ID - b.ID })
+	} else {
+		slices.SortFunc(snippets, func(a, b Snippet) int { return b.ID - a.ID })
+	}
+
+	if len(snippets) > limit+1 {
+		snippets = snippets[:limit+1]
+	}
+
+	for i := range snippets {
+		snippets[i] = cloneSnippet(snippets[i])
+	}
+
+	return newPage(snippets, limit, c)
+}
+
+func (m *MemorySnippetModel) TagCounts(limit int) ([]TagCount, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	now := time.Now()
+	totals := make(map[string]int)
+
+	for _, s := range m.DB.snippets {
+		if !isListed(s, now) {
+			continue
+		}
+		for _, tag := range s.Tags {
+			totals[tag]++
+		}
+	}
+
+	var counts []TagCount
+
+	for name, count := range totals {
+		counts = append(counts, TagCount{Name: name, Count: count})
+	}
+
+	slices.SortFunc(counts, func(a, b TagCount) int {
+		return cmp.Or(b.Count-a.Count, strings.Compare(a.Name, b.Name))
+	})
+
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+
+	return counts, nil
+}
+
+func (m *MemorySnippetModel) ForUser(userID int) ([]Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var snippets []Snippet
+
+	for _, s := range m.DB.snippets {
+		if s.UserID == userID && s.DeletedAt.IsZero() {
+			snippets = append(snippets, cloneSnippet(s))
+		}
+	}
+
+	slices.SortFunc(snippets, func(a, b Snippet) int { return b.ID - a.ID })
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) All(limit int) ([]Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var snippets []Snippet
+
+	for _, s := range m.DB.snippets {
+		if s.DeletedAt.IsZero() {
+			snippets = append(snippets, cloneSnippet(s))
+		}
+	}
+
+	slices.SortFunc(snippets, func(a, b Snippet) int { return b.ID - a.ID })
+
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) Forks(id int) ([]Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	now := time.Now()
+
+	var snippets []Snippet
+
+	for _, s := range m.DB.snippets {
+		if s.ParentID == id && isLive(s, now) {
+			snippets = append(snippets, cloneSnippet(s))
+		}
+	}
+
+	slices.SortFunc(snippets, func(a, b Snippet) int { return b.ID - a.ID })
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) Update(id int, p SnippetParams) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !isLive(s, now) {
+		return ErrNoRecord
+	}
+
+	s.Title = p.Title
+	s.Content = p.Content
+	s.Language = p.Language
+	s.Visibility = p.Visibility
+	s.Expires = now.AddDate(0, 0, p.Expires)
+	s.Tags = memoryTags(p.Tags)
+
+	m.DB.snippets[id] = s
+	m.addRevision(id, p.Title, p.Content, now)
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Delete(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if ok && s.DeletedAt.IsZero() {
+		s.DeletedAt = time.Now().UTC()
+		m.DB.snippets[id] = s
+	}
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Extend(id, days int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !s.DeletedAt.IsZero() {
+		return ErrNoRecord
+	}
+
+	from := time.Now().UTC()
+	if s.Expires.After(from) {
+		from = s.Expires
+	}
+
+	s.Expires = from.AddDate(0, 0, days)
+	m.DB.snippets[id] = s
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Remove(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.snippets[id]; !ok {
+		return ErrNoRecord
+	}
+
+	m.remove(id)
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Trash(userID int, retention time.Duration) ([]Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	cutoff := time.Now().Add(-retention)
+
+	var snippets []Snippet
+
+	for _, s := range m.DB.snippets {
+		if s.UserID == userID && s.DeletedAt.After(cutoff) {
+			snippets = append(snippets, cloneSnippet(s))
+		}
+	}
+
+	slices.SortFunc(snippets, func(a, b Snippet) int { return b.DeletedAt.Compare(a.DeletedAt) })
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) Restore(userID, id int, retention time.Duration) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || s.UserID != userID || !s.DeletedAt.After(time.Now().Add(-retention)) {
+		return ErrNoRecord
+	}
+
+	s.DeletedAt = time.Time{}
+	m.DB.snippets[id] = s
+
+	return nil
+}
+
+func (m *MemorySnippetModel) Purge(retention time.Duration) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	cutoff := time.Now().Add(-retention)
+
+	var n int
+
+	for id, s := range m.DB.snippets {
+		if !s.DeletedAt.IsZero() && !s.DeletedAt.After(cutoff) {
+			m.remove(id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *MemorySnippetModel) PurgeExpired(limit int) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := time.Now()
+
+	var n int
+
+	for id, s := range m.DB.snippets {
+		if n == limit {
+			break
+		}
+		if !s.Expires.After(now) {
+			m.remove(id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *MemorySnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	revisions := slices.Clone(m.DB.revisions[snippetID])
+	slices.Reverse(revisions)
+
+	return revisions, nil
+}
+
+func (m *MemorySnippetModel) Revision(snippetID, version int) (Revision, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	revisions := m.DB.revisions[snippetID]
+	if version < 1 || version > len(revisions) {
+		return Revision{}, ErrNoRecord
+	}
+
+	return revisions[version-1], nil
+}
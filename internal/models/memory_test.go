@@ -0,0 +1,83 @@
+package models
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"wakisa.com/internal/assert"
+)
+
+func TestMemoryDBLoadFixtures(t *testing.T) {
+	f, err := os.Open("./testdata/fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	db := NewMemoryDB()
+
+	err = db.LoadFixtures(f)
+	assert.NilError(t, err)
+
+	users := &MemoryUserModel{DB: db}
+	snippets := &MemorySnippetModel{DB: db}
+
+	id, err := users.Authenticate("bob@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 2)
+
+	// The burn-after-reading snippet isn't listed.
+	page, err := snippets.Latest(Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 3)
+
+	s, err := snippets.GetBySlug("hello-go")
+	assert.NilError(t, err)
+	assert.Equal(t, s.UserID, 2)
+	assert.Equal(t, s.Language, "go")
+
+	s, err = snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Language, "plaintext")
+	assert.Equal(t, s.Visibility, VisibilityPublic)
+}
+
+func TestMemoryDBLoadFixturesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures string
+		wantErr  string
+	}{
+		{
+			name:     "Malformed JSON",
+			fixtures: `{"users": [`,
+			wantErr:  "fixtures: unexpected EOF",
+		},
+		{
+			name:     "Unknown field",
+			fixtures: `{"users": [{"name": "Alice", "email": "alice@example.com", "pasword": "pa$$word"}]}`,
+			wantErr:  `unknown field "pasword"`,
+		},
+		{
+			name:     "Duplicate email",
+			fixtures: `{"users": [{"name": "Alice", "email": "alice@example.com"}, {"name": "Alice", "email": "alice@example.com"}]}`,
+			wantErr:  "fixtures: user alice@example.com: models: duplicate email",
+		},
+		{
+			name:     "Unknown user",
+			fixtures: `{"snippets": [{"user": "carol@example.com", "title": "Title", "content": "Content", "expires": 1}]}`,
+			wantErr:  `fixtures: snippet 1: no user with email "carol@example.com"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewMemoryDB().LoadFixtures(strings.NewReader(tt.fixtures))
+			if err == nil {
+				t.Fatal("got nil error")
+			}
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
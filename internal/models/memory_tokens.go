@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"slices"
+	"time"
+)
+
+// Define a MemoryTokenModel type which implements TokenModelInterface on top
+// of a MemoryDB.
+type MemoryTokenModel struct {
+	DB *MemoryDB
+}
+
+func (m *MemoryTokenModel) Insert(userID int, name string, scopes []string, expires int) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.users[userID]; !ok {
+		return "", errMemoryNoUser
+	}
+
+	now := time.Now().UTC()
+
+	m.DB.lastTokenID++
+
+	t := Token{
+		ID:      m.DB.lastTokenID,
+		UserID:  userID,
+		Name:    name,
+		Hash:    hash,
+		Scopes:  slices.Clone(scopes),
+		Created: now,
+	}
+
+	// An expires value of 0 means the token never expires, which we record
+	// with the zero time.
+	if expires > 0 {
+		t.Expires = now.AddDate(0, 0, expires)
+	}
+
+	m.DB.tokens[t.ID] = t
+
+	return plaintext, nil
+}
+
+func (m *MemoryTokenModel) GetForToken(plaintext string) (Token, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+	now := time.Now().UTC()
+
+	// We record when the token was used, so this needs the write lock.
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for id, t := range m.DB.tokens {
+		if !bytes.Equal(t.Hash, hash[:]) {
+			continue
+		}
+
+		if !t.Expires.IsZero() && !t.Expires.After(now) {
+			break
+		}
+
+		if m.DB.users[t.UserID].Disabled {
+			break
+		}
+
+		// Like the SQL models, we return the token as it was before this
+		// use was recorded.
+		found := t
+		t.LastUsed = now
+		m.DB.tokens[id] = t
+
+		return cloneToken(found), nil
+	}
+
+	return Token{}, ErrNoRecord
+}
+
+func (m *MemoryTokenModel) ForUser(userID int) ([]Token, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var tokens []Token
+
+	for _, t := range m.DB.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, cloneToken(t))
+		}
+	}
+
+	slices.SortFunc(tokens, func(a, b Token) int { return b.ID - a.ID })
+
+	return tokens, nil
+}
+
+func (m *MemoryTokenModel) Revoke(userID, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t, ok := m.DB.tokens[id]
+	if !ok || t.UserID != userID {
+		return ErrNoRecord
+	}
+
+	delete(m.DB.tokens, id)
+
+	return nil
+}
+
+// The cloneToken() function returns a copy of a token which doesn't share
+// any slices with the stored one.
+func cloneToken(t Token) Token {
+	t.Hash = slices.Clone(t.Hash)
+	t.Scopes = slices.Clone(t.Scopes)
+	return t
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a MemoryUserModel type which implements UserModelInterface on top
+// of a MemoryDB.
+type MemoryUserModel struct {
+	DB *MemoryDB
+}
+
+func (m *MemoryUserModel) Insert(name, email, password string) error {
+	// Hash the password before taking the lock, as bcrypt is deliberately
+	// slow.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return ErrDuplicateEmail
+		}
+	}
+
+	m.DB.lastUserID++
+
+	m.DB.users[m.DB.lastUserID] = User{
+		ID:             m.DB.lastUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	}
+
+	return nil
+}
+
+func (m *MemoryUserModel) Authenticate(email, password string) (int, error) {
+	m.DB.mu.RLock()
+
+	var user User
+	var found bool
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			user, found = u, true
+			break
+		}
+	}
+
+	m.DB.mu.RUnlock()
+
+	if !found {
+		return 0, ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	if user.Disabled {
+		return 0, ErrAccountDisabled
+	}
+
+	return user.ID, nil
+}
+
+func (m *MemoryUserModel) Exists(id int) (bool, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	user, ok := m.DB.users[id]
+
+	return ok && !user.Disabled, nil
+}
+
+// As with the SQL models, the hashed password is left out of the result.
+func (m *MemoryUserModel) Get(id int) (User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	user, ok := m.DB.users[id]
+	if !ok {
+		return User{}, ErrNoRecord
+	}
+
+	user.HashedPassword = nil
+
+	return user, nil
+}
+
+func (m *MemoryUserModel) GetByEmail(email string) (User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, user := range m.DB.users {
+		if user.Email == email {
+			user.HashedPassword = nil
+			return user, nil
+		}
+	}
+
+	return User{}, ErrNoRecord
+}
+
+func (m *MemoryUserModel) List() ([]User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	var users []User
+
+	for _, user := range m.DB.users {
+		user.HashedPassword = nil
+		users = append(users, user)
+	}
+
+	slices.SortFunc(users, func(a, b User) int { return a.ID - b.ID })
+
+	return users, nil
+}
+
+func (m *MemoryUserModel) SetPassword(id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	return m.update(id, func(user *User) { user.HashedPassword = hashedPassword })
+}
+
+func (m *MemoryUserModel) SetDisabled(id int, disabled bool) error {
+	return m.update(id, func(user *User) { user.Disabled = disabled })
+}
+
+// The update() helper applies a change to a user, returning ErrNoRecord if
+// there's no user with the given ID.
+func (m *MemoryUserModel) update(id int, change func(user *User)) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	user, ok := m.DB.users[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	change(&user)
+	m.DB.users[id] = user
+
+	return nil
+}
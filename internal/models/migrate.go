@@ -0,0 +1,534 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The schema for each SQL backend is built up by a numbered series of
+// migrations, which live in the migrations directory and are embedded in the
+// binary. Every migration has two files, one to apply it and one to undo it:
+//
+//	migrations/<driver>/0001_initial_schema.up.sql
+//	migrations/<driver>/0001_initial_schema.down.sql
+//
+// The schema_migrations table records which of them have been applied to a
+// database. To change the schema, add a new pair of files with the next
+// number for every driver -- never edit a migration once it's been released,
+// as databases which have already applied it won't pick up the change.
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Match the name of a migration file, capturing its version number, name and
+// direction.
+var migrationFileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Define a Migration type to hold a single schema migration.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Define a MigrationStatus type to describe whether a migration has been
+// applied to a database. AppliedAt is the zero time if it hasn't.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// IsApplied() returns true if the migration has been applied.
+func (s MigrationStatus) IsApplied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// The statements which create the schema_migrations table for each driver.
+// It has to exist before any migration can be recorded, so it's the one
+// table which isn't created by a migration.
+var migrationsTables = map[string]string{
+	"mysql":    `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME NOT NULL)`,
+	"postgres": `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL)`,
+	"sqlite":   `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, name TEXT NOT NULL, applied_at DATETIME NOT NULL)`,
+}
+
+// Migrations() returns the migrations for a database driver, in version
+// order.
+func Migrations(driver string) ([]Migration, error) {
+	if _, ok := migrationsTables[driver]; !ok {
+		return nil, fmt.Errorf("models: no migrations for driver %q", driver)
+	}
+
+	dir := path.Join("migrations", driver)
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		match := migrationFileRX.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("models: badly named migration file %s", path.Join(dir, entry.Name()))
+		}
+
+		version, _ := strconv.Atoi(match[1])
+
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("models: migration %d for %s has two names, %s and %s", version, driver, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	var migrations []Migration
+
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("models: migration %d_%s for %s needs both an up and a down file", m.Version, m.Name, driver)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+// Define a Migrator type which applies and rolls back the migrations for a
+// database. Driver is the name of the database driver: mysql, postgres or
+// sqlite.
+type Migrator struct {
+	DB     *sql.DB
+	Driver string
+}
+
+// The Status() method returns every migration along with whether it has
+// been applied, in version order.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := Migrations(m.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Migration: migration, AppliedAt: applied[migration.Version]}
+	}
+
+	return statuses, nil
+}
+
+// The Pending() method returns the migrations which haven't been applied
+// yet, in the order Up() would apply them.
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+
+	for _, s := range statuses {
+		if !s.IsApplied() {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// The Up() method applies every pending migration in version order, and
+// returns the ones it applied. If a migration fails, the ones before it stay
+// applied and the error says which one it was.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	// Some of the changes may be in the database already, made before there
+	// were migrations to make them. Their migrations are recorded as applied
+	// rather than being run.
+	adopted, err := m.adopted(pending)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	for _, migration := range pending {
+		if slices.Contains(adopted, migration.Version) {
+			err = m.record(migration)
+			if err != nil {
+				return done, err
+			}
+
+			done = append(done, migration)
+			continue
+		}
+
+		err = m.run(migration.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(m.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES(?, ?, ?)"), migration.Version, migration.Name, time.Now().UTC().Truncate(time.Second))
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("models: applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// The schemas which Up() recognises in a database whose migrations weren't
+// all recorded, each with the migrations it stands in for. They're described
+// by their tables and columns, which are the same for every driver.
+var knownSchemas = []struct {
+	tables   map[string][]string
+	versions []int
+}{
+	{
+		// The whole schema in one go. The first version of migration 0001
+		// created this, as did the application itself for SQLite and
+		// PostgreSQL before there were migrations. Migrations 0002 to 0004
+		// came afterwards, so they still need to run.
+		tables: map[string][]string{
+			"users":             {"id", "name", "email", "hashed_password", "created"},
+			"snippets":          {"id", "slug", "user_id", "title", "content", "language", "visibility", "created", "expires", "deleted_at", "hashed_password", "views_left"},
+			"snippet_revisions": {"id", "snippet_id", "version", "title", "content", "created"},
+			"tags":              {"id", "name"},
+			"snippet_tags":      {"snippet_id", "tag_id"},
+			"tokens":            {"id", "user_id", "name", "hash", "scopes", "created", "expires", "last_used"},
+		},
+		versions: []int{1, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	},
+	{
+		// The original schema, which MySQL databases were set up with by
+		// hand, and which migration 0001 creates.
+		tables: map[string][]string{
+			"users":    {"id", "name", "email", "hashed_password", "created"},
+			"snippets": {"id", "title", "content", "created", "expires"},
+		},
+		versions: []int{1},
+	},
+}
+
+// The queries which list the columns of every table in the database, for
+// each driver.
+var columnQueries = map[string]string{
+	"mysql":    `SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = DATABASE()`,
+	"postgres": `SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = current_schema()`,
+	"sqlite":   `SELECT m.name, p.name FROM sqlite_master m JOIN pragma_table_info(m.name) p WHERE m.type = 'table'`,
+}
+
+// The adopted() method returns the versions of the pending migrations whose
+// changes are already in the database, so that Up() can record them without
+// running them. It looks for the first of knownSchemas whose tables are all
+// there with all their columns, and whose migrations after the first are all
+// pending -- otherwise a column could have been left behind by a migration
+// which has since been rolled back. If the first migration is pending and
+// some, but not all, of its tables and columns are there, the database can't
+// be brought up to date automatically, so it returns an error wrapping
+// ErrSchemaMismatch which says what's missing.
+func (m *Migrator) adopted(pending []Migration) ([]int, error) {
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	existing, err := m.columns()
+	if err != nil {
+		return nil, err
+	}
+
+	isPending := make(map[int]bool)
+	for _, migration := range pending {
+		isPending[migration.Version] = true
+	}
+
+	for _, known := range knownSchemas {
+		if len(missingColumns(existing, known.tables)) > 0 {
+			continue
+		}
+
+		var versions []int
+
+		for i, version := range known.versions {
+			if isPending[version] {
+				versions = append(versions, version)
+			} else if i > 0 {
+				versions = nil
+				break
+			}
+		}
+
+		if versions != nil {
+			return versions, nil
+		}
+	}
+
+	// The last of knownSchemas is the schema migration 0001 creates. If none
+	// of its tables are there, it can run as normal.
+	if isPending[1] {
+		initial := knownSchemas[len(knownSchemas)-1].tables
+
+		for table := range initial {
+			if _, ok := existing[table]; ok {
+				return nil, fmt.Errorf("%w: %s missing", ErrSchemaMismatch, strings.Join(missingColumns(existing, initial), ", "))
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// The columns() method returns the columns of every table in the database,
+// by table.
+func (m *Migrator) columns() (map[string][]string, error) {
+	rows, err := m.DB.Query(columnQueries[m.Driver])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string][]string)
+
+	for rows.Next() {
+		var table, column string
+
+		err = rows.Scan(&table, &column)
+		if err != nil {
+			return nil, err
+		}
+
+		// MySQL reports names in upper case on some platforms.
+		table, column = strings.ToLower(table), strings.ToLower(column)
+		existing[table] = append(existing[table], column)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// The missingColumns() function returns the tables, and the table.column
+// pairs for tables which are there, which are in want but not in existing,
+// sorted. A missing table only appears once, on its own.
+func missingColumns(existing, want map[string][]string) []string {
+	var missing []string
+
+	for table, columns := range want {
+		have, ok := existing[table]
+		if !ok {
+			missing = append(missing, table)
+			continue
+		}
+
+		for _, column := range columns {
+			if !slices.Contains(have, column) {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+
+	slices.Sort(missing)
+
+	return missing
+}
+
+// The record() method marks a migration as applied without running it.
+func (m *Migrator) record(migration Migration) error {
+	_, err := m.DB.Exec(m.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES(?, ?, ?)"), migration.Version, migration.Name, time.Now().UTC().Truncate(time.Second))
+	return err
+}
+
+// The Down() method rolls back the most recently applied migrations, newest
+// first, and returns the ones it rolled back. It rolls back at most steps
+// migrations; pass a negative number to roll back all of them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(statuses)
+
+	var done []Migration
+
+	for _, s := range statuses {
+		if len(done) == steps {
+			break
+		}
+		if !s.IsApplied() {
+			continue
+		}
+
+		migration := s.Migration
+
+		err = m.run(migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(m.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("models: rolling back migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// The applied() method creates the schema_migrations table if it doesn't
+// exist, and returns when each of the applied migrations was applied, by
+// version.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	stmt, ok := migrationsTables[m.Driver]
+	if !ok {
+		return nil, fmt.Errorf("models: no migrations for driver %q", m.Driver)
+	}
+
+	_, err := m.DB.Exec(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// The run() method executes the statements in a migration file, followed by
+// record, which updates schema_migrations, all in one transaction. SQLite and
+// PostgreSQL can roll back schema changes, so a migration which fails part
+// way through leaves no trace. MySQL commits each schema change as soon as
+// it's made, so there a failed migration may need tidying up by hand.
+func (m *Migrator) run(script string, record func(tx *sql.Tx) error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// The rebind() method rewrites the ? placeholders in a statement as $1, $2
+// and so on for PostgreSQL.
+func (m *Migrator) rebind(stmt string) string {
+	if m.Driver != "postgres" {
+		return stmt
+	}
+
+	var b strings.Builder
+	n := 0
+
+	for _, r := range stmt {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// The splitStatements() function splits a migration file into its separate
+// statements, so that they can be executed one at a time. (The MySQL driver
+// only accepts several statements at once when the DSN includes
+// multiStatements=true.) A statement ends with a semicolon at the end of a
+// line, and comments on lines of their own are dropped.
+func splitStatements(script string) []string {
+	var stmts []string
+	var current []string
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current = append(current, line)
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = nil
+		}
+	}
+
+	if len(current) > 0 {
+		stmts = append(stmts, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+
+	return stmts
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"wakisa.com/internal/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	// Every driver has the same migrations, with both an up and a down file.
+	var want []Migration
+
+	for _, driver := range []string{"mysql", "postgres", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			migrations, err := Migrations(driver)
+			assert.NilError(t, err)
+
+			if want == nil {
+				want = migrations
+			}
+
+			assert.Equal(t, len(migrations), len(want))
+
+			for i, m := range migrations {
+				assert.Equal(t, m.Version, i+1)
+				assert.Equal(t, m.Name, want[i].Name)
+			}
+		})
+	}
+
+	_, err := Migrations("oracle")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := &Migrator{DB: db, Driver: "sqlite"}
+
+	all, err := Migrations("sqlite")
+	assert.NilError(t, err)
+
+	pending, err := m.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), len(all))
+
+	done, err := m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(done), len(all))
+
+	// Running Up() again is a no-op.
+	done, err = m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(done), 0)
+
+	statuses, err := m.Status()
+	assert.NilError(t, err)
+	for _, s := range statuses {
+		assert.Equal(t, s.IsApplied(), true)
+	}
+
+	// Down() rolls back the newest migrations first.
+	done, err = m.Down(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(done), 1)
+	assert.Equal(t, done[0].Version, all[len(all)-1].Version)
+
+	pending, err = m.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 1)
+
+	done, err = m.Down(-1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(done), len(all)-1)
+
+	// Once everything has been rolled back, only schema_migrations is left.
+	var tables int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").Scan(&tables)
+	assert.NilError(t, err)
+	assert.Equal(t, tables, 1)
+}
+
+func TestMigratorExistingSchema(t *testing.T) {
+	all, err := Migrations("sqlite")
+	assert.NilError(t, err)
+
+	// The newTestDB() helper opens an empty SQLite database and runs the
+	// given statements against it.
+	newTestDB := func(t *testing.T, stmts ...string) *Migrator {
+		db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		for _, stmt := range stmts {
+			_, err = db.Exec(stmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return &Migrator{DB: db, Driver: "sqlite"}
+	}
+
+	t.Run("Baseline schema", func(t *testing.T) {
+		// A database set up by hand before there were migrations, with a
+		// snippet in it. The first migration is recorded without being run,
+		// and the rest bring the snippet up to date.
+		m := newTestDB(t,
+			"CREATE TABLE users (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, email TEXT NOT NULL, hashed_password TEXT NOT NULL, created DATETIME NOT NULL)",
+			"CREATE TABLE snippets (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, content TEXT NOT NULL, created DATETIME NOT NULL, expires DATETIME NOT NULL)",
+			"INSERT INTO users (name, email, hashed_password, created) VALUES ('Alice', 'alice@example.com', 'x', '2022-01-01 09:18:24')",
+			"INSERT INTO snippets (title, content, created, expires) VALUES ('O snail', 'Climb Mount Fuji', '2022-01-01 10:00:00', '2099-01-01 10:00:00')",
+		)
+
+		done, err := m.Up()
+		assert.NilError(t, err)
+		assert.Equal(t, len(done), len(all))
+
+		var userID, revisions int
+		var slug, language, visibility string
+
+		err = m.DB.QueryRow("SELECT user_id, slug, language, visibility FROM snippets WHERE id = 1").Scan(&userID, &slug, &language, &visibility)
+		assert.NilError(t, err)
+		assert.Equal(t, userID, 1)
+		assert.Equal(t, slug, "snippet-1")
+		assert.Equal(t, language, "plaintext")
+		assert.Equal(t, visibility, "public")
+
+		err = m.DB.QueryRow("SELECT COUNT(*) FROM snippet_revisions WHERE snippet_id = 1 AND version = 1").Scan(&revisions)
+		assert.NilError(t, err)
+		assert.Equal(t, revisions, 1)
+	})
+
+	t.Run("Incomplete schema", func(t *testing.T) {
+		// Only some of the tables are there, so there's no telling what the
+		// rest of the database should look like.
+		m := newTestDB(t,
+			"CREATE TABLE users (id INTEGER NOT NULL PRIMARY KEY, name TEXT NOT NULL, email TEXT NOT NULL, hashed_password TEXT NOT NULL, created DATETIME NOT NULL)",
+		)
+
+		done, err := m.Up()
+		assert.Equal(t, errors.Is(err, ErrSchemaMismatch), true)
+		assert.StringContains(t, err.Error(), "snippets missing")
+		assert.Equal(t, len(done), 0)
+
+		// Nothing is recorded as applied.
+		pending, err := m.Pending()
+		assert.NilError(t, err)
+		assert.Equal(t, len(pending), len(all))
+	})
+
+	t.Run("Original schema", func(t *testing.T) {
+		// The first version of migration 0001 created everything which
+		// migrations 0005 onwards add, so on a database which ran it they're
+		// recorded without being run. Running them would fail, as the
+		// columns they add are already there.
+		m := newTestDB(t)
+
+		_, err := m.Up()
+		assert.NilError(t, err)
+
+		_, err = m.DB.Exec("DELETE FROM schema_migrations WHERE version >= 5")
+		assert.NilError(t, err)
+
+		done, err := m.Up()
+		assert.NilError(t, err)
+		assert.Equal(t, len(done), len(all)-4)
+		assert.Equal(t, done[0].Version, 5)
+	})
+
+	t.Run("Rolled back", func(t *testing.T) {
+		// Rolling back the last migration leaves every column in place, but
+		// the migration still has to run again to put its index back.
+		m := newTestDB(t)
+
+		_, err := m.Up()
+		assert.NilError(t, err)
+
+		_, err = m.Down(1)
+		assert.NilError(t, err)
+
+		done, err := m.Up()
+		assert.NilError(t, err)
+		assert.Equal(t, len(done), 1)
+
+		var indexes int
+		err = m.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_snippets_expires'").Scan(&indexes)
+		assert.NilError(t, err)
+		assert.Equal(t, indexes, 1)
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- A comment.
+CREATE TABLE a (
+    id INTEGER -- trailing comments stay
+);
+
+DROP TABLE b;
+DROP TABLE c`
+
+	stmts := splitStatements(script)
+
+	assert.Equal(t, len(stmts), 3)
+	assert.Equal(t, stmts[0], "CREATE TABLE a (\n    id INTEGER -- trailing comments stay\n)")
+	assert.Equal(t, stmts[1], "DROP TABLE b")
+	assert.Equal(t, stmts[2], "DROP TABLE c")
+}
@@ -0,0 +1,30 @@
+-- The original tables for users and snippets, as databases were set up by
+-- hand before there were migrations. Everything since then is added by the
+-- migrations which follow.
+--
+-- If the tables already exist, Migrator.Up() records this migration as
+-- applied without running it. A database which has only some of them, or
+-- which is missing some of their columns, makes it fail with
+-- ErrSchemaMismatch instead.
+--
+-- The first version of this migration created the whole schema at once.
+-- Migrator.Up() recognises a database which ran it, and records the later
+-- migrations which make the same changes without running them.
+
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    INDEX idx_snippets_created (created)
+);
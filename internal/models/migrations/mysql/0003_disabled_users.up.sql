@@ -0,0 +1,4 @@
+-- Lets an administrator disable a user's account. A disabled user can't log
+-- in or use their tokens, but their snippets are left alone.
+
+ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- Records which snippet a snippet was forked from. There's deliberately no
+-- foreign key: a fork outlives its parent, and the application treats a
+-- parent_id which no longer matches a live snippet as having no parent.
+
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL, ADD INDEX idx_snippets_parent_id (parent_id);
@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP FOREIGN KEY snippets_fk_user;
+
+ALTER TABLE snippets DROP COLUMN user_id;
@@ -0,0 +1,11 @@
+-- Records which user created each snippet. Snippets created before they
+-- had owners are given to the first user who signed up. If there are
+-- snippets but no users the migration fails, as there's nobody to give them
+-- to; sign up first, then run it again.
+
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+UPDATE snippets SET user_id = (SELECT MIN(id) FROM users);
+
+ALTER TABLE snippets MODIFY user_id INTEGER NOT NULL,
+    ADD CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
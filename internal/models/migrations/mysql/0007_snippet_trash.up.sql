@@ -0,0 +1,4 @@
+-- Deleting a snippet moves it to the trash, by setting deleted_at, rather
+-- than removing the row. Existing snippets start out not deleted.
+
+ALTER TABLE snippets ADD COLUMN deleted_at DATETIME NULL, ADD INDEX idx_snippets_deleted_at (deleted_at);
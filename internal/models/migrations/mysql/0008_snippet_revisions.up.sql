@@ -0,0 +1,21 @@
+-- Every version of a snippet's title and content, so that edits can be
+-- compared and nothing is lost. The content of each existing snippet is
+-- recorded as its first revision.
+--
+-- The table is only created if it doesn't exist because the first version
+-- of migration 0001 created it along with everything else.
+
+CREATE TABLE IF NOT EXISTS snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version),
+    CONSTRAINT snippet_revisions_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+INSERT INTO snippet_revisions (snippet_id, version, title, content, created)
+SELECT id, 1, title, content, created FROM snippets
+WHERE id NOT IN (SELECT snippet_id FROM snippet_revisions);
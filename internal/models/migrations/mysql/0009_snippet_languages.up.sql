@@ -0,0 +1,4 @@
+-- The language each snippet is highlighted as. Existing snippets are plain
+-- text.
+
+ALTER TABLE snippets ADD COLUMN language VARCHAR(20) NOT NULL DEFAULT 'plaintext';
@@ -0,0 +1,4 @@
+-- The index which Search() uses to find snippets by their title and
+-- content.
+
+ALTER TABLE snippets ADD FULLTEXT INDEX idx_snippets_fulltext (title, content);
@@ -0,0 +1,5 @@
+-- Whether each snippet is public, unlisted or private. Existing snippets
+-- stay public, as they were.
+
+ALTER TABLE snippets ADD COLUMN visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
+    ADD INDEX idx_snippets_visibility (visibility);
@@ -0,0 +1,10 @@
+-- The slug each snippet is addressed by. Existing snippets get the slug
+-- snippet-<id>, which matches SlugRX and can't clash with a random slug, as
+-- those have no hyphens. Their old numeric URLs carry on redirecting to the
+-- new ones.
+
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32) NULL;
+
+UPDATE snippets SET slug = CONCAT('snippet-', id);
+
+ALTER TABLE snippets MODIFY slug VARCHAR(32) NOT NULL, ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
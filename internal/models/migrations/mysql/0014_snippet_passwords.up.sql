@@ -0,0 +1,4 @@
+-- The bcrypt hash of a protected snippet's password, or NULL if it isn't
+-- protected. Existing snippets aren't.
+
+ALTER TABLE snippets ADD COLUMN hashed_password CHAR(60) NULL;
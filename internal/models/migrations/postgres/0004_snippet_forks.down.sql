@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_parent_id;
+ALTER TABLE snippets DROP COLUMN parent_id;
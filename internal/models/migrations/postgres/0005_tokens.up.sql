@@ -0,0 +1,17 @@
+-- Personal access tokens for the API. Only a hash of each token is stored.
+--
+-- The table is only created if it doesn't exist because the first version
+-- of migration 0001 created it along with everything else.
+
+CREATE TABLE IF NOT EXISTS tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hash BYTEA NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    expires TIMESTAMPTZ,
+    last_used TIMESTAMPTZ,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash),
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
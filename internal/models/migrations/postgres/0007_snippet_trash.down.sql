@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_deleted_at;
+ALTER TABLE snippets DROP COLUMN deleted_at;
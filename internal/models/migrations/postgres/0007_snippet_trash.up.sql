@@ -0,0 +1,5 @@
+-- Deleting a snippet moves it to the trash, by setting deleted_at, rather
+-- than removing the row. Existing snippets start out not deleted.
+
+ALTER TABLE snippets ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+CREATE INDEX idx_snippets_deleted_at ON snippets(deleted_at);
@@ -0,0 +1,4 @@
+-- The equivalent of the MySQL FULLTEXT index. Search() has to use exactly the
+-- same expression for PostgreSQL to use the index.
+
+CREATE INDEX idx_snippets_fulltext ON snippets USING GIN (to_tsvector('english', title || ' ' || content));
@@ -0,0 +1,5 @@
+-- Whether each snippet is public, unlisted or private. Existing snippets
+-- stay public, as they were.
+
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private'));
+CREATE INDEX idx_snippets_visibility ON snippets(visibility);
@@ -0,0 +1,12 @@
+-- The slug each snippet is addressed by. Existing snippets get the slug
+-- snippet-<id>, which matches SlugRX and can't clash with a random slug, as
+-- those have no hyphens. Their old numeric URLs carry on redirecting to the
+-- new ones.
+
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32);
+
+UPDATE snippets SET slug = 'snippet-' || id;
+
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
@@ -0,0 +1,4 @@
+-- How many more times a view-limited snippet can be viewed before it's
+-- destroyed, or NULL if there's no limit. Existing snippets have none.
+
+ALTER TABLE snippets ADD COLUMN views_left INTEGER NULL;
@@ -0,0 +1,31 @@
+-- The original tables for users and snippets, with the types SQLite
+-- understands. Everything since then is added by the migrations which
+-- follow.
+--
+-- If the tables already exist, Migrator.Up() records this migration as
+-- applied without running it. A database which has only some of them, or
+-- which is missing some of their columns, makes it fail with
+-- ErrSchemaMismatch instead.
+--
+-- The first version of this migration created the whole schema at once.
+-- Migrator.Up() recognises a database which ran it, and records the later
+-- migrations which make the same changes without running them.
+
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
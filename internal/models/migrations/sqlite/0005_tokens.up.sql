@@ -0,0 +1,17 @@
+-- Personal access tokens for the API. Only a hash of each token is stored.
+--
+-- The table is only created if it doesn't exist because the first version
+-- of migration 0001 created it along with everything else.
+
+CREATE TABLE IF NOT EXISTS tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    hash BLOB NOT NULL,
+    scopes TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME,
+    last_used DATETIME,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash),
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
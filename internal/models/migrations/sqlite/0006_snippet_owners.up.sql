@@ -0,0 +1,10 @@
+-- Records which user created each snippet. Snippets created before they
+-- had owners are given to the first user who signed up.
+--
+-- SQLite won't add a column which has a foreign key unless it defaults to
+-- NULL, so unlike the other drivers the column isn't NOT NULL. Every
+-- snippet the application creates has an owner all the same.
+
+ALTER TABLE snippets ADD COLUMN user_id INTEGER CONSTRAINT snippets_fk_user REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE snippets SET user_id = (SELECT MIN(id) FROM users);
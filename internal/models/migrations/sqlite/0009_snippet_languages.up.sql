@@ -0,0 +1,4 @@
+-- The language each snippet is highlighted as. Existing snippets are plain
+-- text.
+
+ALTER TABLE snippets ADD COLUMN language TEXT NOT NULL DEFAULT 'plaintext';
@@ -0,0 +1,3 @@
+-- SQLite's Search() matches words with LIKE, which can't use an index, so
+-- there's nothing to do here. The migration exists so that every driver has
+-- the same ones.
@@ -0,0 +1,13 @@
+-- The slug each snippet is addressed by. Existing snippets get the slug
+-- snippet-<id>, which matches SlugRX and can't clash with a random slug, as
+-- those have no hyphens. Their old numeric URLs carry on redirecting to the
+-- new ones.
+--
+-- SQLite can't add a column with a UNIQUE constraint, so uniqueness comes
+-- from an index instead, and a NOT NULL column has to have a default.
+
+ALTER TABLE snippets ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+
+UPDATE snippets SET slug = 'snippet-' || id;
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
@@ -0,0 +1,4 @@
+-- Lets the background purge find expired snippets without reading the
+-- whole table.
+
+CREATE INDEX idx_snippets_expires ON snippets(expires);
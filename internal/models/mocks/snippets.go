@@ -1,34 +1,395 @@
 package mocks
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"wakisa.com/internal/models"
 )
 
 var mockSnippet = models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	Slug:       "silentpond",
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "plaintext",
+	Tags:       []string{"haiku", "poetry"},
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+var mockOtherUserSnippet = models.Snippet{
+	ID:         4,
+	Slug:       "autumnmorn",
+	UserID:     2,
+	Title:      "First autumn morning",
+	Content:    "First autumn morning: the mirror I stare into...",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+var mockDeletedSnippet = models.Snippet{
+	ID:         5,
+	Slug:       "candlelight",
+	UserID:     1,
+	Title:      "The light of a candle",
+	Content:    "The light of a candle is transferred to another candle...",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now().Add(-48 * time.Hour),
+	Expires:    time.Now().Add(24 * time.Hour),
+	DeletedAt:  time.Now().Add(-time.Hour),
+}
+
+var mockExpiredSnippet = models.Snippet{
+	ID:         3,
+	Slug:       "wintryforest",
+	UserID:     1,
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest, winds howl in rage...",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now().Add(-48 * time.Hour),
+	Expires:    time.Now().Add(-24 * time.Hour),
+}
+
+var mockMarkdownSnippet = models.Snippet{
+	ID:         6,
+	Slug:       "runbook",
+	UserID:     1,
+	Title:      "Restarting the server",
+	Content:    "# Runbook\n\nRun `make restart`.<script>alert(1)</script>",
+	Language:   "markdown",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+var mockPrivateSnippet = models.Snippet{
+	ID:         7,
+	Slug:       "dbpassword",
+	UserID:     1,
+	Title:      "Database password",
+	Content:    "hunter2",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+var mockUnlistedSnippet = models.Snippet{
+	ID:         8,
+	Slug:       "stagehosts",
+	UserID:     2,
+	Title:      "Staging hostnames",
+	Content:    "staging.example.com",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+// The password for this snippet is "open sesame".
+var mockProtectedSnippet = models.Snippet{
+	ID:             9,
+	Slug:           "lockedbox",
+	UserID:         2,
+	Title:          "Locked box",
+	Content:        "The key is under the mat",
+	Language:       "plaintext",
+	Visibility:     models.VisibilityPublic,
+	Created:        time.Now(),
+	Expires:        time.Now().Add(24 * time.Hour),
+	HashedPassword: []byte("$2a$12$mockmockmockmockmockmockmockmockmockmockmockmockmockm"),
+}
+
+var mockBurnSnippet = models.Snippet{
+	ID:         10,
+	Slug:       "burnnote",
+	UserID:     2,
+	Title:      "Burn after reading",
+	Content:    "The eagle lands at midnight",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+	ViewsLeft:  1,
+}
+
+// Both forks of mockSnippet belong to bob, and only the public one should be
+// listed for anybody else.
+var mockForkSnippet = models.Snippet{
+	ID:         11,
+	Slug:       "pondremix",
+	UserID:     2,
+	Title:      "An old silent pond (remix)",
+	Content:    "An old silent pond... a frog jumps in.",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+	ParentID:   1,
+}
+
+var mockUnlistedForkSnippet = models.Snippet{
+	ID:         12,
+	Slug:       "pondsecret",
+	UserID:     2,
+	Title:      "An old silent pond (draft)",
+	Content:    "An old silent pond... splash!",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+	ParentID:   1,
+}
+
+// A fork of mockDeletedSnippet, so its parent has gone.
+var mockOrphanForkSnippet = models.Snippet{
+	ID:         14,
+	Slug:       "orphanfork",
+	UserID:     2,
+	Title:      "Candlelight (remix)",
+	Content:    "The light of a candle is transferred to another candle.",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+	ParentID:   5,
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+// Insert() pretends to create a snippet with ID 13, and the slug
+// "newsnippet" unless a vanity slug is given.
+func (m *SnippetModel) Insert(userID int, p models.SnippetParams) (int, string, error) {
+	switch p.Slug {
+	case "":
+		return 13, "newsnippet", nil
+	case mockSnippet.Slug:
+		return 0, "", models.ErrDuplicateSlug
+	default:
+		return 13, p.Slug, nil
+	}
 }
 
 func (m *SnippetModel) Get(id int) (models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 4:
+		return mockOtherUserSnippet, nil
+	case 6:
+		return mockMarkdownSnippet, nil
+	case 7:
+		return mockPrivateSnippet, nil
+	case 8:
+		return mockUnlistedSnippet, nil
+	case 9:
+		return mockProtectedSnippet, nil
+	case 10:
+		return mockBurnSnippet, nil
+	case 11:
+		return mockForkSnippet, nil
+	case 12:
+		return mockUnlistedForkSnippet, nil
+	case 14:
+		return mockOrphanForkSnippet, nil
 	default:
 		return models.Snippet{}, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]models.Snippet, error) {
-	return []models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) GetBySlug(slug string) (models.Snippet, error) {
+	for _, s := range []models.Snippet{mockSnippet, mockOtherUserSnippet, mockMarkdownSnippet, mockPrivateSnippet, mockUnlistedSnippet, mockProtectedSnippet, mockBurnSnippet, mockForkSnippet, mockUnlistedForkSnippet, mockOrphanForkSnippet} {
+		if s.Slug == slug {
+			return s, nil
+		}
+	}
+
+	return models.Snippet{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) GetOwned(userID int, slug string) (models.Snippet, error) {
+	for _, s := range []models.Snippet{mockSnippet, mockOtherUserSnippet, mockExpiredSnippet, mockMarkdownSnippet, mockPrivateSnippet, mockUnlistedSnippet, mockProtectedSnippet, mockBurnSnippet, mockForkSnippet, mockUnlistedForkSnippet, mockOrphanForkSnippet} {
+		if s.Slug == slug && s.UserID == userID {
+			return s, nil
+		}
+	}
+
+	return models.Snippet{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) VerifyPassword(id int, password string) error {
+	if id == mockProtectedSnippet.ID && password == "open sesame" {
+		return nil
+	}
+
+	return models.ErrInvalidCredentials
+}
+
+func (m *SnippetModel) Consume(id int) (models.Snippet, error) {
+	return m.Get(id)
+}
+
+func (m *SnippetModel) Latest(c models.Cursor) (models.Page, error) {
+	page := models.Page{
+		Snippets: []models.Snippet{mockSnippet},
+		Limit:    models.DefaultPageSize,
+	}
+
+	if c.Limit > 0 {
+		page.Limit = c.Limit
+	}
+
+	// Pretend there are newer snippets whenever we've paged back in time.
+	if c.Before > 0 {
+		page.Prev = mockSnippet.ID
+	}
+
+	return page, nil
+}
+
+// Search() does a naive case-insensitive match of each word in the query
+// against the titles and content of the live mock snippets.
+func (m *SnippetModel) Search(query string, c models.Cursor) (models.Page, error) {
+	page := models.Page{Limit: models.DefaultPageSize}
+
+	for _, s := range []models.Snippet{mockMarkdownSnippet, mockOtherUserSnippet, mockSnippet} {
+		text := strings.ToLower(s.Title + " " + s.Content)
+
+		for _, term := range strings.Fields(strings.ToLower(query)) {
+			if strings.Contains(text, term) {
+				page.Snippets = append(page.Snippets, s)
+				break
+			}
+		}
+	}
+
+	return page, nil
+}
+
+func (m *SnippetModel) ForTag(tag string, c models.Cursor) (models.Page, error) {
+	page := models.Page{Limit: models.DefaultPageSize}
+
+	if slices.Contains(mockSnippet.Tags, tag) {
+		page.Snippets = []models.Snippet{mockSnippet}
+	}
+
+	return page, nil
+}
+
+func (m *SnippetModel) TagCounts(limit int) ([]models.TagCount, error) {
+	return []models.TagCount{{Name: "haiku", Count: 1}, {Name: "poetry", Count: 1}}, nil
+}
+
+func (m *SnippetModel) ForUser(userID int) ([]models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []models.Snippet{mockSnippet, mockExpiredSnippet}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (m *SnippetModel) All(limit int) ([]models.Snippet, error) {
+	return []models.Snippet{mockSnippet, mockExpiredSnippet}, nil
+}
+
+func (m *SnippetModel) Update(id int, p models.SnippetParams) error {
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	return nil
+}
+
+func (m *SnippetModel) Extend(id, days int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Remove(id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Trash(userID int, retention time.Duration) ([]models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []models.Snippet{mockDeletedSnippet}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (m *SnippetModel) Restore(userID, id int, retention time.Duration) error {
+	if userID == 1 && id == 5 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Purge(retention time.Duration) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) PurgeExpired(limit int) (int, error) {
+	return 0, nil
+}
+
+var mockRevisions = []models.Revision{
+	{
+		ID:        2,
+		SnippetID: 1,
+		Version:   2,
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...",
+		Created:   time.Now(),
+	},
+	{
+		ID:        1,
+		SnippetID: 1,
+		Version:   1,
+		Title:     "An old silent pond",
+		Content:   "An old slient pond...",
+		Created:   time.Now().Add(-time.Hour),
+	},
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]models.Revision, error) {
+	switch snippetID {
+	case 1:
+		return mockRevisions, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (m *SnippetModel) Revision(snippetID, version int) (models.Revision, error) {
+	if snippetID == 1 {
+		for _, rev := range mockRevisions {
+			if rev.Version == version {
+				return rev, nil
+			}
+		}
+	}
+	return models.Revision{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Forks(id int) ([]models.Snippet, error) {
+	if id == 1 {
+		return []models.Snippet{mockUnlistedForkSnippet, mockForkSnippet}, nil
+	}
+	return nil, nil
 }
@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"time"
+
+	"wakisa.com/internal/models"
+)
+
+var mockToken = models.Token{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI deploys",
+	Scopes:  []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+	Created: time.Now(),
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expires int) (string, error) {
+	return "NEWTOKENNEWTOKENNEWTOKENNE", nil
+}
+
+func (m *TokenModel) GetForToken(plaintext string) (models.Token, error) {
+	switch plaintext {
+	case "VALIDTOKENVALIDTOKENVALIDT":
+		return mockToken, nil
+	case "READONLYTOKENREADONLYTOKEN":
+		t := mockToken
+		t.Scopes = []string{models.ScopeSnippetsRead}
+		return t, nil
+	default:
+		return models.Token{}, models.ErrNoRecord
+	}
+}
+
+func (m *TokenModel) ForUser(userID int) ([]models.Token, error) {
+	if userID == 1 {
+		return []models.Token{mockToken}, nil
+	}
+	return nil, nil
+}
+
+func (m *TokenModel) Revoke(userID, id int) error {
+	if userID == 1 && id == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
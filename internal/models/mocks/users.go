@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"time"
+
 	"wakisa.com/internal/models"
 )
 
@@ -19,6 +21,9 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	if email == "alice@example.com" && password == "pa$$word" {
 		return 1, nil
 	}
+	if email == "disabled@example.com" && password == "pa$$word" {
+		return 0, models.ErrAccountDisabled
+	}
 	return 0, models.ErrInvalidCredentials
 }
 
@@ -31,3 +36,48 @@ func (m *UserModel) Exists(id int) (bool, error) {
 
 	}
 }
+
+func (m *UserModel) Get(id int) (models.User, error) {
+	switch id {
+	case 1:
+		return models.User{
+			ID:      1,
+			Name:    "Alice Jones",
+			Email:   "alice@example.com",
+			Created: time.Now(),
+		}, nil
+	default:
+		return models.User{}, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetByEmail(email string) (models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return m.Get(1)
+	default:
+		return models.User{}, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) List() ([]models.User, error) {
+	user, _ := m.Get(1)
+	return []models.User{user}, nil
+}
+
+func (m *UserModel) SetPassword(id int, password string) error {
+	return m.update(id)
+}
+
+func (m *UserModel) SetDisabled(id int, disabled bool) error {
+	return m.update(id)
+}
+
+func (m *UserModel) update(id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Open() opens a connection pool to the database for the given driver --
+// mysql, postgres or sqlite -- and checks that it's working. Both the web
+// application and the admin tool connect through it, so they always agree
+// on how it's done.
+func Open(driver, dsn string) (*sql.DB, error) {
+	switch driver {
+	case "mysql":
+		return OpenMySQL(dsn)
+	case "postgres":
+		return OpenPostgres(dsn)
+	case "sqlite":
+		return OpenSQLite(dsn)
+	}
+
+	return nil, fmt.Errorf("models: unknown database driver %q", driver)
+}
+
+// OpenMySQL() opens a connection pool to the MySQL database with the given
+// DSN, and checks that it's working. The DSN needs the parseTime=true
+// parameter, so that the driver converts DATETIME columns to time.Time.
+func OpenMySQL(dsn string) (*sql.DB, error) {
+	// The sql.Open() function doesn't actually create any connections, it
+	// just initializes the pool for future use. So we call db.Ping() to
+	// create a connection and check for any errors.
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
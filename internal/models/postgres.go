@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// The PostgreSQL backend uses the pgx driver through database/sql, so the
+// Postgres models look much like the MySQL ones. The differences are in the
+// SQL itself: placeholders are written $1, $2 and so on rather than ?, NOW()
+// and make_interval() take the place of UTC_TIMESTAMP() and DATE_ADD(), and
+// new IDs come back from a RETURNING clause because there's no
+// LastInsertId(). Times are stored in TIMESTAMPTZ columns, so they're always
+// unambiguous whatever the server's time zone is.
+
+// The SQLSTATE code PostgreSQL uses for a unique_violation error.
+const postgresUniqueViolation = "23505"
+
+// OpenPostgres() opens a connection pool to the PostgreSQL database with the
+// given DSN (either a postgres:// URL or key=value pairs), and checks that
+// it's working. The schema is created by the migrations (see Migrator).
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// The isPostgresUniqueViolation() function returns true if err is
+// PostgreSQL complaining about a duplicate value for the given unique
+// constraint. It's the equivalent of checking for MySQL error 1062.
+func isPostgresUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == postgresUniqueViolation && pgErr.ConstraintName == constraint
+	}
+	return false
+}
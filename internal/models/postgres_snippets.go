@@ -0,0 +1,538 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Define a PostgresSnippetModel type which implements SnippetModelInterface
+// on top of a PostgreSQL database opened with OpenPostgres(). It behaves
+// exactly like the MySQL SnippetModel; see there for what each method does.
+type PostgresSnippetModel struct {
+	DB *sql.DB
+}
+
+func (m *PostgresSnippetModel) Insert(userID int, p SnippetParams) (int, string, error) {
+	return insertWithSlug(p, func(p SnippetParams) (int, error) {
+		return m.insert(userID, p)
+	})
+}
+
+func (m *PostgresSnippetModel) insert(userID int, p SnippetParams) (int, error) {
+	hashedPassword, err := hashSnippetPassword(p.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	// Pass NULL rather than an empty value when there's no password, view
+	// limit or parent.
+	var password, viewsLeft, parentID any
+	if hashedPassword != nil {
+		password = string(hashedPassword)
+	}
+	if p.MaxViews > 0 {
+		viewsLeft = p.MaxViews
+	}
+	if p.ParentID > 0 {
+		parentID = p.ParentID
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, language, visibility, hashed_password, views_left, parent_id, created, expires)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW() + make_interval(days => $10))
+	RETURNING id`
+
+	var id int
+
+	err = tx.QueryRow(stmt, p.Slug, userID, p.Title, p.Content, p.Language, p.Visibility, password, viewsLeft, parentID, p.Expires).Scan(&id)
+	if err != nil {
+		if isPostgresUniqueViolation(err, "snippets_uc_slug") {
+			return 0, ErrDuplicateSlug
+		}
+		return 0, err
+	}
+
+	err = postgresInsertRevision(tx, id, p.Title, p.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	err = postgresSetTags(tx, id, p.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (m *PostgresSnippetModel) Get(id int) (Snippet, error) {
+	return m.get("id = $1", id)
+}
+
+func (m *PostgresSnippetModel) GetBySlug(slug string) (Snippet, error) {
+	return m.get("slug = $1", slug)
+}
+
+func (m *PostgresSnippetModel) GetOwned(userID int, slug string) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = $1 AND slug = $2 AND deleted_at IS NULL`
+
+	return firstSnippet(postgresQuerySnippets(m.DB, stmt, userID, slug))
+}
+
+// The get() helper fetches a single live snippet matching the condition.
+func (m *PostgresSnippetModel) get(cond string, arg any) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > NOW() AND deleted_at IS NULL AND ` + cond
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+
+	err = postgresAttachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+func (m *PostgresSnippetModel) VerifyPassword(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := "SELECT hashed_password FROM snippets WHERE id = $1 AND hashed_password IS NOT NULL"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return compareSnippetPassword(hashedPassword, password)
+}
+
+// As in MySQL, SELECT ... FOR UPDATE locks the row until the transaction
+// ends. A second reader waiting on the lock sees the row as it was left by
+// the first: gone, or with one view fewer.
+func (m *PostgresSnippetModel) Consume(id int) (Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > NOW() AND deleted_at IS NULL AND id = $1 FOR UPDATE`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+
+	err = postgresAttachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	switch {
+	case s.ViewsLeft == 1:
+		_, err = tx.Exec("DELETE FROM snippets WHERE id = $1", id)
+	case s.ViewsLeft > 1:
+		_, err = tx.Exec("UPDATE snippets SET views_left = views_left - 1 WHERE id = $1", id)
+	}
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+func (m *PostgresSnippetModel) Latest(c Cursor) (Page, error) {
+	return m.page("", nil, c)
+}
+
+// PostgreSQL's full text search stands in for MySQL's FULLTEXT index. The
+// query is parsed with plainto_tsquery(), which, like natural language mode,
+// treats every character as ordinary text rather than an operator. Unlike
+// MySQL it only matches snippets containing all of the words.
+func (m *PostgresSnippetModel) Search(query string, c Cursor) (Page, error) {
+	filter := `hashed_password IS NULL AND
+	to_tsvector('english', title || ' ' || content) @@ plainto_tsquery('english', $1)`
+
+	return m.page(filter, []any{query}, c)
+}
+
+func (m *PostgresSnippetModel) ForTag(tag string, c Cursor) (Page, error) {
+	filter := `id IN (SELECT st.snippet_id FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id WHERE t.name = $1)`
+
+	return m.page(filter, []any{tag}, c)
+}
+
+// The page() helper is the PostgreSQL version of SnippetModel.page(). The
+// placeholders in the filter are numbered from $1, and the ones for the
+// cursor are numbered on from there.
+func (m *PostgresSnippetModel) page(filter string, args []any, c Cursor) (Page, error) {
+	limit := c.size()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > NOW() AND deleted_at IS NULL AND visibility = 'public' AND views_left IS NULL`
+
+	if filter != "" {
+		stmt += ` AND ` + filter
+	}
+
+	// The arg() function adds a value to args and returns its placeholder.
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	switch {
+	case c.After > 0:
+		stmt += ` AND id > ` + arg(c.After) + ` ORDER BY id ASC LIMIT ` + arg(limit+1)
+	case c.Before > 0:
+		stmt += ` AND id < ` + arg(c.Before) + ` ORDER BY id DESC LIMIT ` + arg(limit+1)
+	default:
+		stmt += ` ORDER BY id DESC LIMIT ` + arg(limit+1)
+	}
+
+	snippets, err := postgresQuerySnippets(m.DB, stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return newPage(snippets, limit, c), nil
+}
+
+func (m *PostgresSnippetModel) TagCounts(limit int) ([]TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	INNER JOIN snippet_tags st ON st.tag_id = t.id
+	INNER JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > NOW() AND s.deleted_at IS NULL AND s.visibility = 'public' AND s.views_left IS NULL
+	GROUP BY t.name ORDER BY COUNT(*) DESC, t.name LIMIT $1`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TagCount
+
+	for rows.Next() {
+		var tc TagCount
+		err = rows.Scan(&tc.Name, &tc.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, tc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (m *PostgresSnippetModel) ForUser(userID int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id DESC`
+
+	return postgresQuerySnippets(m.DB, stmt, userID)
+}
+
+func (m *PostgresSnippetModel) All(limit int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE deleted_at IS NULL ORDER BY id DESC LIMIT $1`
+
+	return postgresQuerySnippets(m.DB, stmt, limit)
+}
+
+func (m *PostgresSnippetModel) Forks(id int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE parent_id = $1 AND expires > NOW() AND deleted_at IS NULL ORDER BY id DESC`
+
+	return postgresQuerySnippets(m.DB, stmt, id)
+}
+
+func (m *PostgresSnippetModel) Update(id int, p SnippetParams) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = $1, content = $2, language = $3, visibility = $4,
+	expires = NOW() + make_interval(days => $5)
+	WHERE id = $6 AND expires > NOW() AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, p.Title, p.Content, p.Language, p.Visibility, p.Expires, id)
+	if err != nil {
+		return err
+	}
+
+	// PostgreSQL counts every row the WHERE clause matched, whether or not
+	// it changed, so no rows means no live snippet.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	err = postgresInsertRevision(tx, id, p.Title, p.Content)
+	if err != nil {
+		return err
+	}
+
+	err = postgresSetTags(tx, id, p.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgresSnippetModel) Delete(id int) error {
+	stmt := `UPDATE snippets SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+func (m *PostgresSnippetModel) Extend(id, days int) error {
+	stmt := `UPDATE snippets SET expires = GREATEST(expires, NOW()) + make_interval(days => $1)
+	WHERE id = $2 AND deleted_at IS NULL`
+
+	return execOne(m.DB, stmt, days, id)
+}
+
+func (m *PostgresSnippetModel) Remove(id int) error {
+	return execOne(m.DB, "DELETE FROM snippets WHERE id = $1", id)
+}
+
+func (m *PostgresSnippetModel) Trash(userID int, retention time.Duration) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = $1 AND deleted_at > NOW() - make_interval(secs => $2)
+	ORDER BY deleted_at DESC`
+
+	return postgresQuerySnippets(m.DB, stmt, userID, retention.Seconds())
+}
+
+func (m *PostgresSnippetModel) Restore(userID, id int, retention time.Duration) error {
+	stmt := `UPDATE snippets SET deleted_at = NULL
+	WHERE id = $1 AND user_id = $2 AND deleted_at > NOW() - make_interval(secs => $3)`
+
+	result, err := m.DB.Exec(stmt, id, userID, retention.Seconds())
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *PostgresSnippetModel) Purge(retention time.Duration) (int, error) {
+	stmt := `DELETE FROM snippets WHERE deleted_at <= NOW() - make_interval(secs => $1)`
+
+	result, err := m.DB.Exec(stmt, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// PostgreSQL doesn't support DELETE ... LIMIT, so we pick out the batch of
+// IDs with a subquery instead.
+func (m *PostgresSnippetModel) PurgeExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires <= NOW() LIMIT $1
+	)`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+func (m *PostgresSnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	stmt := `SELECT id, snippet_id, version, title, content, created FROM snippet_revisions
+	WHERE snippet_id = $1 ORDER BY version DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+
+	for rows.Next() {
+		var rev Revision
+		err = rows.Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m *PostgresSnippetModel) Revision(snippetID, version int) (Revision, error) {
+	stmt := `SELECT id, snippet_id, version, title, content, created FROM snippet_revisions
+	WHERE snippet_id = $1 AND version = $2`
+
+	var rev Revision
+
+	err := m.DB.QueryRow(stmt, snippetID, version).Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, ErrNoRecord
+		}
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
+
+// The postgresQuerySnippets() function is the PostgreSQL version of
+// querySnippets().
+func postgresQuerySnippets(db *sql.DB, stmt string, args ...any) ([]Snippet, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	err = postgresAttachTags(db, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// The postgresAttachTags() function is the PostgreSQL version of
+// attachTags(). Rather than a placeholder for each snippet, it passes all
+// the IDs as a single array parameter.
+func postgresAttachTags(db *sql.DB, snippets []Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(snippets))
+	for i, s := range snippets {
+		ids[i] = s.ID
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id = ANY($1)
+	ORDER BY t.name`
+
+	return fillTags(db, snippets, stmt, ids)
+}
+
+// The postgresInsertRevision() function is the PostgreSQL version of
+// insertRevision(). PostgreSQL can't work out the types of placeholders in a
+// SELECT list, so they're cast explicitly.
+func postgresInsertRevision(tx *sql.Tx, snippetID int, title, content string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, title, content, created)
+	SELECT $1::integer, COALESCE(MAX(version), 0) + 1, $2::text, $3::text, NOW()
+	FROM snippet_revisions WHERE snippet_id = $1`
+
+	_, err := tx.Exec(stmt, snippetID, title, content)
+	return err
+}
+
+// The postgresSetTags() function is the PostgreSQL version of setTags().
+// ON CONFLICT ... DO UPDATE makes the RETURNING clause give us the ID of the
+// existing tag when there is one; with DO NOTHING it would return no rows.
+func postgresSetTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = $1", snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		var tagID int
+
+		stmt := "INSERT INTO tags (name) VALUES($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id"
+
+		err = tx.QueryRow(stmt, name).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO snippet_tags (snippet_id, tag_id) VALUES($1, $2)", snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Define a PostgresTokenModel type which implements TokenModelInterface on
+// top of a PostgreSQL database.
+type PostgresTokenModel struct {
+	DB *sql.DB
+}
+
+func (m *PostgresTokenModel) Insert(userID int, name string, scopes []string, expires int) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	// As in the MySQL model, an expires value of 0 means the token never
+	// expires, which we store as NULL.
+	stmt := `INSERT INTO tokens (user_id, name, hash, scopes, created, expires)
+	VALUES($1, $2, $3, $4, NOW(), CASE WHEN $5::integer > 0 THEN NOW() + make_interval(days => $5::integer) END)`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash, strings.Join(scopes, ","), expires)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+func (m *PostgresTokenModel) GetForToken(plaintext string) (Token, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	stmt := `SELECT id, user_id, name, hash, scopes, created, expires, last_used FROM tokens
+	WHERE hash = $1 AND (expires IS NULL OR expires > NOW())
+	AND user_id IN (SELECT id FROM users WHERE NOT disabled)`
+
+	t, err := scanToken(m.DB.QueryRow(stmt, hash[:]))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, ErrNoRecord
+		}
+		return Token{}, err
+	}
+
+	_, err = m.DB.Exec("UPDATE tokens SET last_used = NOW() WHERE id = $1", t.ID)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return t, nil
+}
+
+func (m *PostgresTokenModel) ForUser(userID int) ([]Token, error) {
+	stmt := `SELECT id, user_id, name, hash, scopes, created, expires, last_used FROM tokens
+	WHERE user_id = $1 ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []Token
+
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (m *PostgresTokenModel) Revoke(userID, id int) error {
+	result, err := m.DB.Exec("DELETE FROM tokens WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
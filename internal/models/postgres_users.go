@@ -0,0 +1,119 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a PostgresUserModel type which implements UserModelInterface on top
+// of a PostgreSQL database opened with OpenPostgres().
+type PostgresUserModel struct {
+	DB *sql.DB
+}
+
+func (m *PostgresUserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES($1, $2, $3, NOW())`
+
+	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	if err != nil {
+		if isPostgresUniqueViolation(err, "users_uc_email") {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *PostgresUserModel) Authenticate(email, password string) (int, error) {
+	var id int
+	var hashedPassword []byte
+	var disabled bool
+
+	stmt := "SELECT id, hashed_password, disabled FROM users WHERE email = $1"
+
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &disabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
+	return id, nil
+}
+
+func (m *PostgresUserModel) Exists(id int) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = $1 AND NOT disabled)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+
+	return exists, err
+}
+
+func (m *PostgresUserModel) Get(id int) (User, error) {
+	return getUser(m.DB, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+}
+
+func (m *PostgresUserModel) GetByEmail(email string) (User, error) {
+	return getUser(m.DB, "SELECT "+userColumns+" FROM users WHERE email = $1", email)
+}
+
+func (m *PostgresUserModel) List() ([]User, error) {
+	return listUsers(m.DB)
+}
+
+func (m *PostgresUserModel) SetPassword(id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	return m.update("UPDATE users SET hashed_password = $1 WHERE id = $2", string(hashedPassword), id)
+}
+
+func (m *PostgresUserModel) SetDisabled(id int, disabled bool) error {
+	return m.update("UPDATE users SET disabled = $1 WHERE id = $2", disabled, id)
+}
+
+// Unlike MySQL, PostgreSQL counts every row an UPDATE matches as affected,
+// even if nothing in it changed, so no rows means no user.
+func (m *PostgresUserModel) update(stmt string, args ...any) error {
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Define a Revision type to hold a snapshot of a snippet's title and content.
+// A new revision is recorded every time a snippet is created or updated, and
+// revisions are numbered from 1 for each snippet.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Version   int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// The insertRevision() function records the next revision of a snippet. It
+// runs inside the transaction which changes the snippet, so the two can never
+// get out of step. The unique key on (snippet_id, version) stops two
+// concurrent updates from claiming the same version number.
+func insertRevision(tx *sql.Tx, snippetID int, title, content string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, title, content, created)
+	SELECT ?, COALESCE(MAX(version), 0) + 1, ?, ?, UTC_TIMESTAMP()
+	FROM snippet_revisions WHERE snippet_id = ?`
+
+	_, err := tx.Exec(stmt, snippetID, title, content, snippetID)
+	return err
+}
+
+// This will return all the revisions of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	stmt := `SELECT id, snippet_id, version, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY version DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+
+	for rows.Next() {
+		var rev Revision
+		err = rows.Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// This will return a specific revision of a snippet, or ErrNoRecord if the
+// snippet has no revision with that version number.
+func (m *SnippetModel) Revision(snippetID, version int) (Revision, error) {
+	stmt := `SELECT id, snippet_id, version, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? AND version = ?`
+
+	var rev Revision
+
+	err := m.DB.QueryRow(stmt, snippetID, version).Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, ErrNoRecord
+		}
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
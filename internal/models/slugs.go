@@ -0,0 +1,49 @@
+package models
+
+import (
+	"crypto/rand"
+	"math/big"
+	"regexp"
+)
+
+// SlugRX matches a valid snippet slug: between 3 and 32 lowercase letters,
+// digits and hyphens, starting with a letter. Requiring a letter at the start
+// means a slug can never be mistaken for one of the numeric IDs which were
+// used in snippet URLs before slugs were introduced.
+var SlugRX = regexp.MustCompile(`^[a-z][a-z0-9-]{2,31}$`)
+
+const (
+	// The length of a randomly generated slug. With 36 possible characters
+	// (and 26 for the first), this gives around 2^51 possible slugs, which is
+	// far too many to find by guessing.
+	slugLength = 10
+
+	// The number of times Insert() tries a new random slug if it clashes with
+	// an existing one.
+	maxSlugAttempts = 3
+
+	slugLetters  = "abcdefghijklmnopqrstuvwxyz"
+	slugAlphabet = slugLetters + "0123456789"
+)
+
+// The generateSlug() function returns a new random slug, using the operating
+// system's CSPRNG so that slugs can't be predicted. It always matches SlugRX.
+func generateSlug() (string, error) {
+	b := make([]byte, slugLength)
+
+	for i := range b {
+		alphabet := slugAlphabet
+		if i == 0 {
+			alphabet = slugLetters
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+
+		b[i] = alphabet[n.Int64()]
+	}
+
+	return string(b), nil
+}
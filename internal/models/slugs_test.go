@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"wakisa.com/internal/assert"
+)
+
+func TestGenerateSlug(t *testing.T) {
+	seen := make(map[string]bool)
+
+	for range 100 {
+		slug, err := generateSlug()
+		assert.NilError(t, err)
+
+		if !SlugRX.MatchString(slug) {
+			t.Errorf("generated slug %q doesn't match SlugRX", slug)
+		}
+
+		if seen[slug] {
+			t.Errorf("generated slug %q twice", slug)
+		}
+		seen[slug] = true
+	}
+}
@@ -3,24 +3,163 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(userID int, p SnippetParams) (int, string, error)
 	Get(id int) (Snippet, error)
-	Latest() ([]Snippet, error)
+	GetBySlug(slug string) (Snippet, error)
+	GetOwned(userID int, slug string) (Snippet, error)
+	VerifyPassword(id int, password string) error
+	Consume(id int) (Snippet, error)
+	Latest(c Cursor) (Page, error)
+	Search(query string, c Cursor) (Page, error)
+	ForTag(tag string, c Cursor) (Page, error)
+	TagCounts(limit int) ([]TagCount, error)
+	ForUser(userID int) ([]Snippet, error)
+	All(limit int) ([]Snippet, error)
+	Update(id int, p SnippetParams) error
+	Delete(id int) error
+	Extend(id, days int) error
+	Remove(id int) error
+	Trash(userID int, retention time.Duration) ([]Snippet, error)
+	Restore(userID, id int, retention time.Duration) error
+	Purge(retention time.Duration) (int, error)
+	PurgeExpired(limit int) (int, error)
+	Revisions(snippetID int) ([]Revision, error)
+	Revision(snippetID, version int) (Revision, error)
+	Forks(id int) ([]Snippet, error)
 }
 
+// Define the visibility settings a snippet can have. Public snippets appear
+// in listings and search results, unlisted snippets can only be reached by
+// someone who has the link, and private snippets can only be seen by the user
+// who created them.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibilities lists every visibility setting, in display order.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
 // Define a snippet type to hold the data for an individual snippet.
 // Notice how the fields of the struct correspond to the fields in
 // our MSQL snippets table?
+//
+// The struct tags control how a snippet is represented in responses from
+// the JSON API.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int       `json:"id"`
+	Slug       string    `json:"slug"`
+	UserID     int       `json:"user_id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Language   string    `json:"language"`
+	Tags       []string  `json:"tags"`
+	Visibility string    `json:"visibility"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	// DeletedAt is the zero time unless the snippet has been moved to the
+	// trash.
+	DeletedAt time.Time `json:"-"`
+	// HashedPassword is nil unless the snippet is password protected.
+	HashedPassword []byte `json:"-"`
+	// ViewsLeft is the number of times the snippet can still be viewed before
+	// it's destroyed, or 0 if there's no limit.
+	ViewsLeft int `json:"views_left,omitempty"`
+	// ParentID is the ID of the snippet this one was forked from, or 0 if it
+	// wasn't forked. The parent may since have expired or been deleted.
+	ParentID int `json:"parent_id,omitempty"`
+}
+
+// Define a SnippetParams type to hold the fields a user supplies when they
+// create or update a snippet. Expires is the number of days from now until
+// the snippet should expire, and Tags replaces any tags the snippet already
+// has. Slug is the vanity slug asked for when the snippet is created, or
+// empty for a random one, and Password is the plain-text password protecting
+// the snippet, or empty for none. MaxViews is the number of views after which
+// the snippet is destroyed (1 means burn after reading), or 0 for no limit.
+// ParentID is the ID of the snippet being forked, or 0 for a new snippet.
+// None of these can be changed once the snippet has been created, so Update()
+// ignores them.
+type SnippetParams struct {
+	Slug       string
+	Password   string
+	MaxViews   int
+	ParentID   int
+	Title      string
+	Content    string
+	Language   string
+	Tags       []string
+	Visibility string
+	Expires    int
+}
+
+// IsExpired() returns true if the snippet's expiry time has passed. Most
+// queries already leave expired snippets out, but a user's own listing
+// includes them, so the templates need a way to tell them apart.
+func (s Snippet) IsExpired() bool {
+	return !s.Expires.After(time.Now())
+}
+
+// The number of snippets on a page of results when the caller doesn't ask for
+// a particular size, and the most they're allowed to ask for.
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 50
+)
+
+// Define a Cursor type to say which page of snippets Latest() should return.
+// Snippets are listed newest first, and pages are identified by the ID of the
+// snippet on either side of them (keyset pagination) rather than by an
+// offset, so a page never shifts or repeats snippets when new ones are
+// created. At most one of Before and After should be set; if neither is, the
+// first page is returned.
+type Cursor struct {
+	// Before returns the snippets older than the snippet with this ID.
+	Before int
+	// After returns the snippets newer than the snippet with this ID.
+	After int
+	// Limit is the page size. It's clamped to between 1 and MaxPageSize, with
+	// 0 meaning DefaultPageSize.
+	Limit int
+}
+
+// Define a Page type to hold one page of snippets. Next and Prev are the
+// cursors for the neighbouring pages: pass Next as Cursor.Before to get the
+// older snippets and Prev as Cursor.After to get the newer ones. They're 0 if
+// there's no page in that direction.
+type Page struct {
+	Snippets []Snippet
+	Limit    int
+	Next     int
+	Prev     int
+}
+
+// VisibleTo() returns true if the user with the given ID is allowed to see
+// the snippet. Pass 0 for an anonymous user. Only private snippets are
+// restricted; unlisted ones are visible to anyone who knows where they are.
+func (s Snippet) VisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || (userID != 0 && s.UserID == userID)
+}
+
+// IsProtected() returns true if the snippet needs a password to view it.
+func (s Snippet) IsProtected() bool {
+	return s.HashedPassword != nil
+}
+
+// IsViewLimited() returns true if the snippet is destroyed after a number of
+// views.
+func (s Snippet) IsViewLimited() bool {
+	return s.ViewsLeft > 0
 }
 
 // Define a SnippetModel type which wraps a sql.DB connection pool.
@@ -28,21 +167,134 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-// This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// The columns selected by every snippet query, in the order that
+// scanSnippet() expects them.
+const snippetColumns = "id, slug, user_id, title, content, language, visibility, created, expires, deleted_at, hashed_password, views_left, parent_id"
+
+// The scanSnippet() helper copies a row selected with snippetColumns into a
+// Snippet struct. It takes care of the nullable deleted_at, views_left and
+// parent_id columns for us (a NULL hashed_password is scanned as a nil slice without
+// any help).
+func scanSnippet(row scanner) (Snippet, error) {
+	var s Snippet
+	var deletedAt sql.NullTime
+	var viewsLeft, parentID sql.NullInt64
+
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.Created, &s.Expires, &deletedAt, &s.HashedPassword, &viewsLeft, &parentID)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	s.DeletedAt = deletedAt.Time
+	s.ViewsLeft = int(viewsLeft.Int64)
+	s.ParentID = int(parentID.Int64)
+
+	return s, nil
+}
+
+// This will insert a new snippet into the database, owned by the user with
+// the given ID, and return its ID and slug. The initial content is also
+// recorded as the snippet's first revision. If the caller didn't ask for a
+// vanity slug, a random one is generated.
+func (m *SnippetModel) Insert(userID int, p SnippetParams) (int, string, error) {
+	return insertWithSlug(p, func(p SnippetParams) (int, error) {
+		return m.insert(userID, p)
+	})
+}
+
+// The insertWithSlug() function calls insert with the vanity slug in p, or,
+// if there isn't one, with a random slug, and returns the new snippet's ID
+// along with the slug it used. It's shared by every SnippetModelInterface
+// implementation, which just need to return ErrDuplicateSlug from insert when
+// the slug is taken.
+func insertWithSlug(p SnippetParams, insert func(p SnippetParams) (int, error)) (int, string, error) {
+	if p.Slug != "" {
+		id, err := insert(p)
+		return id, p.Slug, err
+	}
+
+	// Random slugs are long enough that a collision is very unlikely, but if
+	// one does happen we just try again with a new slug.
+	for range maxSlugAttempts {
+		slug, err := generateSlug()
+		if err != nil {
+			return 0, "", err
+		}
+
+		p.Slug = slug
+
+		id, err := insert(p)
+		if errors.Is(err, ErrDuplicateSlug) {
+			continue
+		}
+
+		return id, slug, err
+	}
+
+	return 0, "", ErrDuplicateSlug
+}
+
+// The hashSnippetPassword() function returns the bcrypt hash to store for a
+// protected snippet, or nil if there's no password.
+func hashSnippetPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// The insert() method does the work for Insert(), with the slug already
+// decided on. It returns the new snippet's ID.
+func (m *SnippetModel) insert(userID int, p SnippetParams) (int, error) {
+	// Protected snippets store a bcrypt hash of their password, in exactly
+	// the same way as UserModel.Insert() does for user passwords. A snippet
+	// without a password stores NULL.
+	hashedPassword, err := hashSnippetPassword(p.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	// The snippet and its first revision need to be written together, so we
+	// do both inside a transaction. Calling Rollback() after a successful
+	// Commit() is a no-op, so it's safe to defer it straight away.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	// Write the SQL statement we want to execute. I've split it over two lines
 	// for readability (which is why it's surrounded with backquotes instead
 	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, language, visibility, hashed_password, views_left, parent_id, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
 
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL statement, followed by the
-	// values for the placeholder parameters: title, content and expiry in
-	// that order. This methdd returns a sql.Result type, which contains some
-	// basic information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	// Use the Exec() method on the transaction to execute the statement. The
+	// first parameter is the SQL statement, followed by the values for the
+	// placeholder parameters: slug, owner, title, content, language,
+	// visibility, password hash, view limit, parent and expiry in that order.
+	// This methdd returns a sql.Result type, which contains some basic
+	// information about what happened when the statement was executed.
+	// A snippet without a view limit, or which isn't a fork, stores NULL.
+	var viewsLeft, parentID sql.NullInt64
+	if p.MaxViews > 0 {
+		viewsLeft = sql.NullInt64{Int64: int64(p.MaxViews), Valid: true}
+	}
+	if p.ParentID > 0 {
+		parentID = sql.NullInt64{Int64: int64(p.ParentID), Valid: true}
+	}
+
+	result, err := tx.Exec(stmt, p.Slug, userID, p.Title, p.Content, p.Language, p.Visibility, hashedPassword, viewsLeft, parentID, p.Expires)
 	if err != nil {
+		// As with duplicate email addresses in UserModel.Insert(), we check
+		// for a clash on the snippets_uc_slug key and return our own error.
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "snippets_uc_slug") {
+				return 0, ErrDuplicateSlug
+			}
+		}
 		return 0, err
 	}
 
@@ -53,17 +305,32 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 		return 0, err
 	}
 
-	// The ID returned has the type int64, so we convert it to an int type
-	// before returning.
+	err = insertRevision(tx, int(id), p.Title, p.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	err = setTags(tx, int(id), p.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
 	return int(id), nil
 }
 
-// This will return a specific snippet based on its id.
+// This will return a specific snippet based on its id, whatever its
+// visibility. It's up to the caller to check Snippet.VisibleTo() before
+// showing it to anyone.
 func (m *SnippetModel) Get(id int) (Snippet, error) {
 	// Write the SQL statement we want to execute. Again, I've
 	// split it over two lines for readability.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL AND id = ?`
 
 	// Use the QueryRow() method on the connection pool to execute our
 	// SQL statement, passing in the untrusted id variable as the value of the
@@ -71,15 +338,10 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 	// holds the result from the database.
 	row := m.DB.QueryRow(stmt, id)
 
-	// Initialize a new zeroed Snippet struct.
-	var s Snippet
-
-	// Use row.Scan() to copy the values fron each field in sql.Row to the
-	// corresponding field in the Snippet struct. Notice that the arguments
-	// to row.Scan are *pointers* to the place you want to copy the data into,
-	// and the number of arguments must be exactly the same as the number of
-	// columns returned by your statment.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// Use the scanSnippet() helper to copy the values from each field in
+	// sql.Row to the corresponding field in a new Snippet struct. Under the
+	// hood this calls row.Scan(), passing *pointers* to each field.
+	s, err := scanSnippet(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -92,64 +354,536 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 		}
 	}
 
+	// Fetch the snippet's tags as well. The attachTags() function works on a
+	// slice, so we wrap the snippet in one.
+	snippets := []Snippet{s}
+
+	err = attachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
 	// If everything went OK, then return the filled Snippet struct
-	return s, nil
+	return snippets[0], nil
+}
+
+// This will return a specific snippet based on its slug. Like Get(), it
+// doesn't check the snippet's visibility.
+func (m *SnippetModel) GetBySlug(slug string) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL AND slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+
+	err = attachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+// This will check the password for a protected snippet. If the snippet
+// doesn't exist, isn't protected or the password doesn't match, we return
+// ErrInvalidCredentials.
+func (m *SnippetModel) VerifyPassword(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := "SELECT hashed_password FROM snippets WHERE id = ? AND hashed_password IS NOT NULL"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return compareSnippetPassword(hashedPassword, password)
+}
+
+// The compareSnippetPassword() function checks a password against the hash
+// stored for a protected snippet, returning ErrInvalidCredentials if it
+// doesn't match.
+func compareSnippetPassword(hashedPassword []byte, password string) error {
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
+
+// This will fetch a snippet for someone to read, using up one of its views if
+// it's view limited. The snippet is returned as it was before the view, so
+// its ViewsLeft includes the view just used; when that was the last one, the
+// snippet has already been deleted for good by the time we return.
+//
+// The row is locked with SELECT ... FOR UPDATE until the transaction ends,
+// so if two people ask for the same snippet at once, the second waits for the
+// first to finish. That way a burn-after-reading snippet can only ever be
+// read once -- the second request finds it gone and gets ErrNoRecord.
+func (m *SnippetModel) Consume(id int) (Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL AND id = ? FOR UPDATE`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	// Fetch the tags before we possibly delete them along with the snippet.
+	snippets := []Snippet{s}
+
+	err = attachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	// A burned snippet is deleted outright rather than moved to the trash,
+	// so there's no way to get it back. The revisions and tags go with it,
+	// thanks to their ON DELETE CASCADE foreign keys.
+	switch {
+	case s.ViewsLeft == 1:
+		_, err = tx.Exec("DELETE FROM snippets WHERE id = ?", id)
+	case s.ViewsLeft > 1:
+		_, err = tx.Exec("UPDATE snippets SET views_left = views_left - 1 WHERE id = ?", id)
+	}
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+// This will return a page of the most recently created public snippets.
+func (m *SnippetModel) Latest(c Cursor) (Page, error) {
+	return m.page("", nil, c)
+}
+
+// This will return a page of the snippets whose title or content matches a
+// search query, newest first. The matching is done by the FULLTEXT index on
+// the title and content columns. We use natural language mode rather than
+// boolean mode, so that characters like + and - in the query are treated as
+// ordinary text rather than operators. As with Latest(), only live, public
+// snippets are included. Password protected snippets are left out too,
+// otherwise searching would be a way to find out what's in them.
+func (m *SnippetModel) Search(query string, c Cursor) (Page, error) {
+	filter := "hashed_password IS NULL AND MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE)"
+
+	return m.page(filter, []any{query}, c)
+}
+
+// The page() helper returns one page of live, public snippets, newest first,
+// using keyset pagination. The optional filter is an extra SQL condition on
+// the snippets, with args holding the values for any placeholders in it.
+// Unlisted and private snippets never appear in these listings, and nor do
+// view-limited ones, which are meant to be read only by whoever they were
+// shared with.
+func (m *SnippetModel) page(filter string, args []any, c Cursor) (Page, error) {
+	limit := c.size()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL AND visibility = 'public' AND views_left IS NULL`
+
+	if filter != "" {
+		stmt += ` AND ` + filter
+	}
+
+	// The ID is unique and only ever goes up, so ordering by it gives us a
+	// stable newest-first order to page through. We ask for one more row than
+	// we need: if it comes back, there's another page beyond this one.
+	//
+	// To page backwards towards newer snippets we have to walk the index in
+	// ascending order from the cursor, and then put the results back in
+	// newest-first order below.
+	switch {
+	case c.After > 0:
+		stmt += ` AND id > ? ORDER BY id ASC LIMIT ?`
+		args = append(args, c.After, limit+1)
+	case c.Before > 0:
+		stmt += ` AND id < ? ORDER BY id DESC LIMIT ?`
+		args = append(args, c.Before, limit+1)
+	default:
+		stmt += ` ORDER BY id DESC LIMIT ?`
+		args = append(args, limit+1)
+	}
+
+	snippets, err := m.query(stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return newPage(snippets, limit, c), nil
+}
+
+// The size() method returns the page size for the cursor, clamped to between
+// 1 and MaxPageSize.
+func (c Cursor) size() int {
+	switch {
+	case c.Limit < 1:
+		return DefaultPageSize
+	case c.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return c.Limit
+	}
 }
 
-// This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]Snippet, error) {
-	// write the SQL statment we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// The newPage() function builds a Page from up to limit+1 snippets fetched
+// for the cursor, in the order the query walked the IDs: ascending from the
+// cursor when paging with After, and descending otherwise.
+func newPage(snippets []Snippet, limit int, c Cursor) Page {
+	more := len(snippets) > limit
+	if more {
+		snippets = snippets[:limit]
+	}
+
+	if c.After > 0 {
+		slices.Reverse(snippets)
+	}
+
+	page := Page{Snippets: snippets, Limit: limit}
+
+	if len(snippets) == 0 {
+		return page
+	}
+
+	// The extra row tells us whether there's a page in the direction we were
+	// travelling. In the other direction there's always a page if we got here
+	// from a cursor, because that's where we came from.
+	newest, oldest := snippets[0].ID, snippets[len(snippets)-1].ID
 
-	// Use the Query() method on the connection pool to execute our
-	// SQL statement. This returns a sql.Rows resultset containing the result of
-	// our query.
-	rows, err := m.DB.Query(stmt)
+	if c.After > 0 {
+		page.Next = oldest
+		if more {
+			page.Prev = newest
+		}
+	} else {
+		if more {
+			page.Next = oldest
+		}
+		if c.Before > 0 {
+			page.Prev = newest
+		}
+	}
+
+	return page
+}
+
+// This will return the snippet with the given slug if it's owned by the user
+// with the given ID. Unlike GetBySlug() it finds snippets which have expired
+// but haven't been purged yet, as they're still listed by ForUser() and their
+// owner can move them to the trash. Snippets already in the trash aren't
+// found.
+func (m *SnippetModel) GetOwned(userID int, slug string) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND slug = ? AND deleted_at IS NULL`
+
+	return firstSnippet(m.query(stmt, userID, slug))
+}
+
+// The firstSnippet() function returns the first of the snippets returned by
+// a query, or ErrNoRecord if there aren't any.
+func firstSnippet(snippets []Snippet, err error) (Snippet, error) {
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	if len(snippets) == 0 {
+		return Snippet{}, ErrNoRecord
+	}
+
+	return snippets[0], nil
+}
+
+// This will return every snippet owned by a specific user, newest first.
+// Unlike Latest() it includes snippets which have already expired, until
+// PurgeExpired() gets round to deleting them. Snippets in the trash are left
+// out; Trash() lists those.
+func (m *SnippetModel) ForUser(userID int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND deleted_at IS NULL ORDER BY id DESC`
+
+	return m.query(stmt, userID)
+}
+
+// This will return up to limit snippets, newest first, whoever owns them and
+// whatever their visibility. Like ForUser() it includes expired snippets but
+// leaves out the ones in the trash. It's meant for administrators, so nothing
+// in the web application uses it.
+func (m *SnippetModel) All(limit int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE deleted_at IS NULL ORDER BY id DESC LIMIT ?`
+
+	return m.query(stmt, limit)
+}
+
+// This will return the live forks of a snippet, newest first, whatever their
+// visibility. It's up to the caller to leave out the ones which shouldn't be
+// listed for the current user.
+func (m *SnippetModel) Forks(id int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE parent_id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL ORDER BY id DESC`
+
+	return m.query(stmt, id)
+}
+
+// The query() helper runs a SELECT statement which returns snippetColumns,
+// and collects the results into a slice along with their tags.
+func (m *SnippetModel) query(stmt string, args ...any) ([]Snippet, error) {
+	return querySnippets(m.DB, stmt, args...)
+}
+
+// The querySnippets() function does the work for query(). It's shared with
+// the other SQL backends.
+func querySnippets(db *sql.DB, stmt string, args ...any) ([]Snippet, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	err = attachTags(db, snippets)
 	if err != nil {
 		return nil, err
 	}
 
-	// We defer rows.Close() to ensure the sql.Rows resultset is
-	// always properly closed before the Latest() method returns. This defer
-	// statement should come *after* you check for an error from the Query()
-	// method. Otherwise, if Query() returns an error, you'll get a panic
-	// trying to close a nil resultset.
+	return snippets, nil
+}
+
+// The scanSnippets() function collects the rows from a query which selected
+// snippetColumns into a slice, and closes them.
+func scanSnippets(rows *sql.Rows) ([]Snippet, error) {
 	defer rows.Close()
 
-	// Iniitalize an empty slice to hold the Snippet structs.
 	var snippets []Snippet
 
-	// Use rows.Next to iterate through the rows in the resultset. This
-	// prepares the first (and then each subsequent) row to be acted on the
-	// rows.Scan() method. If iteration over all the rows completes then the
-	// resultset automatically closes itself and frees up the underlying
-	// database connection.
 	for rows.Next() {
-		// create a pointer to a new zeroed Snippet struct.
-		var s Snippet
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet object that we created. Again, the arguments to row.Scan()
-		// must be pointers to the place you want to copy the data into, and the
-		// number of arguments must be exaclty the same as the number of
-		// columns returned by your statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		// Append it to the slice snippets.
 		snippets = append(snippets, s)
 	}
 
-	// When the rows.Next() loop has finished we call rows.Err() to retrieve any
-	// error that was encoutered during the iteration. It's important to
-	// call this don't assume that a successful iteration was completed
-	// over the whole resultset.
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	// If everything went OK then return the Snippet slice.
 	return snippets, nil
 }
+
+// This will update the details of an existing snippet, and reset
+// its expiry to the given number of days from now. The new content is
+// recorded as a new revision, so nothing is lost. If there's no such live
+// snippet (it doesn't exist, has expired or is in the trash) we return
+// ErrNoRecord. Checking that the caller is allowed to change the snippet is
+// left to the handler.
+func (m *SnippetModel) Update(id int, p SnippetParams) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?,
+	expires = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY)
+	WHERE id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, p.Title, p.Content, p.Language, p.Visibility, p.Expires, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// As in UserModel.update(), MySQL only counts the rows which actually
+	// changed, so saving a snippet twice in the same second with nothing
+	// different affects no rows. When that happens we check whether the
+	// snippet is there at all.
+	if n == 0 {
+		var exists bool
+
+		stmt = `SELECT EXISTS(SELECT true FROM snippets
+		WHERE id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL)`
+
+		err = tx.QueryRow(stmt, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	err = insertRevision(tx, id, p.Title, p.Content)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, id, p.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// This will soft delete a snippet by moving it to the trash. The row stays
+// in the table, but Get(), Latest() and ForUser() will no longer return it.
+// As with Update(), checking ownership is left to the handler.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `UPDATE snippets SET deleted_at = UTC_TIMESTAMP()
+	WHERE id = ? AND deleted_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// This will push a snippet's expiry back by the given number of days, which
+// must be positive. If the snippet has already expired (but not yet been
+// purged), the days are counted from now instead, which brings it back. It
+// doesn't apply to snippets in the trash; if there's no such snippet we
+// return ErrNoRecord.
+func (m *SnippetModel) Extend(id, days int) error {
+	stmt := `UPDATE snippets SET expires = DATE_ADD(GREATEST(expires, UTC_TIMESTAMP()), INTERVAL ? DAY)
+	WHERE id = ? AND deleted_at IS NULL`
+
+	return execOne(m.DB, stmt, days, id)
+}
+
+// This will permanently delete a snippet, whether or not it's in the trash,
+// along with its revisions and tags. Unlike Delete() there's no getting it
+// back. If there's no such snippet we return ErrNoRecord.
+func (m *SnippetModel) Remove(id int) error {
+	return execOne(m.DB, "DELETE FROM snippets WHERE id = ?", id)
+}
+
+// The execOne() function runs a statement which should change exactly one
+// row, returning ErrNoRecord if it didn't change any.
+func execOne(db *sql.DB, stmt string, args ...any) error {
+	result, err := db.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will return the snippets in a user's trash which were deleted within
+// the retention window, most recently deleted first. Anything older is due
+// to be purged and can no longer be restored.
+func (m *SnippetModel) Trash(userID int, retention time.Duration) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	ORDER BY deleted_at DESC`
+
+	return m.query(stmt, userID, int(retention.Seconds()))
+}
+
+// This will take a snippet back out of the trash. It only matches snippets
+// owned by the given user which are still within the retention window; if
+// there's no such snippet we return ErrNoRecord.
+func (m *SnippetModel) Restore(userID, id int, retention time.Duration) error {
+	stmt := `UPDATE snippets SET deleted_at = NULL
+	WHERE id = ? AND user_id = ? AND deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	result, err := m.DB.Exec(stmt, id, userID, int(retention.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will permanently delete every snippet which has been in the trash for
+// longer than the retention window, returning the number of rows removed.
+func (m *SnippetModel) Purge(retention time.Duration) (int, error) {
+	stmt := `DELETE FROM snippets
+	WHERE deleted_at <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	result, err := m.DB.Exec(stmt, int(retention.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// This will permanently delete up to limit snippets which have expired,
+// returning the number of rows removed. Deleting in batches keeps each
+// statement short, so it doesn't hold locks on the snippets table for long
+// when there's a big backlog; the caller keeps going until a batch comes back
+// smaller than the limit.
+func (m *SnippetModel) PurgeExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
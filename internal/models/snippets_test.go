@@ -0,0 +1,257 @@
+package models
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"wakisa.com/internal/assert"
+)
+
+func TestSnippetModelLatest(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	// Create five snippets. They get the IDs 1 to 5.
+	for range 5 {
+		_, _, err := m.Insert(1, SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7})
+		assert.NilError(t, err)
+	}
+
+	ids := func(p Page) []int {
+		var ids []int
+		for _, s := range p.Snippets {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	first, err := m.Latest(Cursor{Limit: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, len(first.Snippets), 2)
+	assert.Equal(t, ids(first)[0], 5)
+	assert.Equal(t, first.Next, 4)
+	assert.Equal(t, first.Prev, 0)
+
+	// A snippet created while we're paging shouldn't change the next page.
+	_, _, err = m.Insert(1, SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7})
+	assert.NilError(t, err)
+
+	second, err := m.Latest(Cursor{Before: first.Next, Limit: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, ids(second)[0], 3)
+	assert.Equal(t, ids(second)[1], 2)
+	assert.Equal(t, second.Next, 2)
+	assert.Equal(t, second.Prev, 3)
+
+	last, err := m.Latest(Cursor{Before: second.Next, Limit: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, len(last.Snippets), 1)
+	assert.Equal(t, last.Next, 0)
+
+	// Paging back again returns the newer snippets in newest-first order.
+	back, err := m.Latest(Cursor{After: second.Prev, Limit: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, ids(back)[0], 5)
+	assert.Equal(t, ids(back)[1], 4)
+	assert.Equal(t, back.Prev, 5)
+	assert.Equal(t, back.Next, 4)
+
+	// The page size is clamped.
+	all, err := m.Latest(Cursor{Limit: MaxPageSize + 1})
+	assert.NilError(t, err)
+	assert.Equal(t, all.Limit, MaxPageSize)
+	assert.Equal(t, len(all.Snippets), 6)
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	params := []SnippetParams{
+		{Title: "Restarting nginx", Content: "sudo systemctl restart nginx", Language: "bash", Visibility: VisibilityPublic, Expires: 7},
+		{Title: "Haiku", Content: "An old silent pond", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7},
+		// Expired and unlisted snippets must never show up in the results.
+		{Title: "Old nginx notes", Content: "nginx -s reload", Language: "bash", Visibility: VisibilityPublic, Expires: -1},
+		{Title: "Internal nginx notes", Content: "nginx -t", Language: "bash", Visibility: VisibilityUnlisted, Expires: 7},
+	}
+
+	for _, p := range params {
+		_, _, err := m.Insert(1, p)
+		assert.NilError(t, err)
+	}
+
+	page, err := m.Search("nginx", Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].Title, "Restarting nginx")
+
+	page, err = m.Search("pond", Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].Title, "Haiku")
+
+	page, err = m.Search("kubernetes", Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 0)
+}
+
+func TestSnippetModelTags(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	_, slug, err := m.Insert(1, SnippetParams{Title: "Restarting nginx", Content: "sudo systemctl restart nginx", Language: "bash", Visibility: VisibilityPublic, Tags: []string{"nginx", "ops"}, Expires: 7})
+	assert.NilError(t, err)
+
+	_, _, err = m.Insert(1, SnippetParams{Title: "Tailing logs", Content: "journalctl -f", Language: "bash", Visibility: VisibilityPublic, Tags: []string{"ops"}, Expires: 7})
+	assert.NilError(t, err)
+
+	s, err := m.GetBySlug(slug)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(s.Tags, ","), "nginx,ops")
+
+	page, err := m.ForTag("ops", Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 2)
+
+	// Updating a snippet replaces its tags.
+	err = m.Update(s.ID, SnippetParams{Title: "Restarting nginx", Content: "sudo systemctl restart nginx", Language: "bash", Visibility: VisibilityPublic, Tags: []string{"web"}, Expires: 7})
+	assert.NilError(t, err)
+
+	page, err = m.ForTag("nginx", Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 0)
+
+	counts, err := m.TagCounts(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(counts), 2)
+	assert.Equal(t, counts[0], TagCount{Name: "ops", Count: 1})
+	assert.Equal(t, counts[1], TagCount{Name: "web", Count: 1})
+}
+
+func TestSnippetModelSlugs(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	params := SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7}
+
+	// Without a vanity slug, a random one is generated.
+	_, slug, err := m.Insert(1, params)
+	assert.NilError(t, err)
+	assert.Equal(t, SlugRX.MatchString(slug), true)
+
+	s, err := m.GetBySlug(slug)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Slug, slug)
+
+	params.Slug = "my-snippet"
+
+	_, slug, err = m.Insert(1, params)
+	assert.NilError(t, err)
+	assert.Equal(t, slug, "my-snippet")
+
+	// Vanity slugs have to be unique.
+	_, _, err = m.Insert(1, params)
+	assert.Equal(t, err, ErrDuplicateSlug)
+
+	_, err = m.GetBySlug("no-such-snippet")
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelPassword(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	_, slug, err := m.Insert(1, SnippetParams{Password: "open sesame", Title: "Locked nginx notes", Content: "nginx -s reload", Language: "bash", Visibility: VisibilityPublic, Expires: 7})
+	assert.NilError(t, err)
+
+	s, err := m.GetBySlug(slug)
+	assert.NilError(t, err)
+	assert.Equal(t, s.IsProtected(), true)
+
+	assert.NilError(t, m.VerifyPassword(s.ID, "open sesame"))
+	assert.Equal(t, m.VerifyPassword(s.ID, "wrong password"), ErrInvalidCredentials)
+
+	// Protected snippets are left out of the search results.
+	page, err := m.Search("nginx", Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 0)
+}
+
+func TestSnippetModelConsume(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	_, slug, err := m.Insert(1, SnippetParams{MaxViews: 2, Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPublic, Expires: 7})
+	assert.NilError(t, err)
+
+	s, err := m.GetBySlug(slug)
+	assert.NilError(t, err)
+	assert.Equal(t, s.ViewsLeft, 2)
+
+	// View-limited snippets are left out of the listings.
+	page, err := m.Latest(Cursor{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 0)
+
+	s, err = m.Consume(s.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, s.ViewsLeft, 2)
+
+	// Only one of two simultaneous readers gets the last view.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Consume(s.ID)
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var consumed, missing int
+	for err := range errs {
+		switch err {
+		case nil:
+			consumed++
+		case ErrNoRecord:
+			missing++
+		default:
+			t.Fatal(err)
+		}
+	}
+
+	assert.Equal(t, consumed, 1)
+	assert.Equal(t, missing, 1)
+
+	_, err = m.Get(s.ID)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	for _, expires := range []int{-1, -1, -1, 7} {
+		_, _, err := m.Insert(1, SnippetParams{Title: "Title", Content: "Content", Language: "plaintext", Visibility: VisibilityPublic, Expires: expires})
+		assert.NilError(t, err)
+	}
+
+	n, err := m.PurgeExpired(2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	n, err = m.PurgeExpired(2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	// The snippet which hasn't expired is still there.
+	snippets, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+}
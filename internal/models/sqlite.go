@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The SQLite backend stores everything in a single file, so snippetbox can be
+// tried out without running a MySQL server. It uses the pure Go SQLite driver
+// from modernc.org, which doesn't need cgo.
+//
+// SQLite doesn't have MySQL's date functions, so the SQLite models work out
+// times in Go and pass them in as parameters. They're always in UTC and
+// rounded to the second, like MySQL's DATETIME columns, so that the driver
+// stores them as text which sorts in time order.
+
+// The pragmas which every connection to the database needs. SQLite doesn't
+// enforce foreign keys unless it's asked to, and we rely on them to delete a
+// snippet's tags and revisions along with it. The busy timeout makes a
+// connection wait for a lock instead of failing straight away, and the
+// write-ahead log lets readers carry on while somebody writes.
+var sqlitePragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
+// OpenSQLite() opens the SQLite database in the given file (or "file:" URI),
+// creating the file if it doesn't already exist. The schema is created by
+// the migrations (see Migrator).
+//
+// Transactions are started with BEGIN IMMEDIATE, which takes the database's
+// write lock straight away. SQLite only allows one writer at a time anyway,
+// and this way a transaction which reads a row and then changes it -- like
+// SQLiteSnippetModel.Consume() -- can't have the row changed underneath it.
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	params := []string{"_txlock=immediate"}
+	for _, pragma := range sqlitePragmas {
+		params = append(params, "_pragma="+pragma)
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite", dsn+sep+strings.Join(params, "&"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// The sqliteNow() function returns the current time, in the form the SQLite
+// models store times in.
+func sqliteNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// The isSQLiteUniqueViolation() function returns true if err is SQLite
+// complaining about a duplicate value for the given column (written as
+// "table.column"). It's the equivalent of checking for MySQL error 1062.
+func isSQLiteUniqueViolation(err error, column string) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), column)
+	}
+	return false
+}
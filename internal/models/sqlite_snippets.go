@@ -0,0 +1,485 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Define a SQLiteSnippetModel type which implements SnippetModelInterface on
+// top of a SQLite database opened with OpenSQLite(). It behaves exactly like
+// the MySQL SnippetModel; see there for what each method does.
+type SQLiteSnippetModel struct {
+	DB *sql.DB
+}
+
+func (m *SQLiteSnippetModel) Insert(userID int, p SnippetParams) (int, string, error) {
+	return insertWithSlug(p, func(p SnippetParams) (int, error) {
+		return m.insert(userID, p)
+	})
+}
+
+func (m *SQLiteSnippetModel) insert(userID int, p SnippetParams) (int, error) {
+	hashedPassword, err := hashSnippetPassword(p.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	// Pass NULL rather than an empty value when there's no password, view
+	// limit or parent.
+	var password, viewsLeft, parentID any
+	if hashedPassword != nil {
+		password = string(hashedPassword)
+	}
+	if p.MaxViews > 0 {
+		viewsLeft = p.MaxViews
+	}
+	if p.ParentID > 0 {
+		parentID = p.ParentID
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := sqliteNow()
+
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, language, visibility, hashed_password, views_left, parent_id, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := tx.Exec(stmt, p.Slug, userID, p.Title, p.Content, p.Language, p.Visibility, password, viewsLeft, parentID, now, now.AddDate(0, 0, p.Expires))
+	if err != nil {
+		if isSQLiteUniqueViolation(err, "snippets.slug") {
+			return 0, ErrDuplicateSlug
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = sqliteInsertRevision(tx, int(id), p.Title, p.Content, now)
+	if err != nil {
+		return 0, err
+	}
+
+	err = sqliteSetTags(tx, int(id), p.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (m *SQLiteSnippetModel) Get(id int) (Snippet, error) {
+	return m.get("id = ?", id)
+}
+
+func (m *SQLiteSnippetModel) GetBySlug(slug string) (Snippet, error) {
+	return m.get("slug = ?", slug)
+}
+
+// The get() helper fetches a single live snippet matching the condition.
+func (m *SQLiteSnippetModel) get(cond string, arg any) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > ? AND deleted_at IS NULL AND ` + cond
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, sqliteNow(), arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+
+	err = attachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+func (m *SQLiteSnippetModel) GetOwned(userID int, slug string) (Snippet, error) {
+	return (&SnippetModel{DB: m.DB}).GetOwned(userID, slug)
+}
+
+func (m *SQLiteSnippetModel) VerifyPassword(id int, password string) error {
+	return (&SnippetModel{DB: m.DB}).VerifyPassword(id, password)
+}
+
+// SQLite doesn't have SELECT ... FOR UPDATE. It doesn't need it, because the
+// transaction begins with BEGIN IMMEDIATE (see OpenSQLite()), so it holds the
+// database's write lock from the start. A second reader waits for the lock,
+// and then finds the snippet gone or with one view fewer.
+func (m *SQLiteSnippetModel) Consume(id int) (Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > ? AND deleted_at IS NULL AND id = ?`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, sqliteNow(), id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+
+	err = attachTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	switch {
+	case s.ViewsLeft == 1:
+		_, err = tx.Exec("DELETE FROM snippets WHERE id = ?", id)
+	case s.ViewsLeft > 1:
+		_, err = tx.Exec("UPDATE snippets SET views_left = views_left - 1 WHERE id = ?", id)
+	}
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+func (m *SQLiteSnippetModel) Latest(c Cursor) (Page, error) {
+	return m.page("", nil, c)
+}
+
+// SQLite has no equivalent of MySQL's FULLTEXT indexes (short of building an
+// FTS5 table), so search is done with LIKE instead: a snippet matches if its
+// title or content contains every word in the query. That's slower and
+// cruder than MySQL's natural language search, but plenty for a local
+// database.
+func (m *SQLiteSnippetModel) Search(query string, c Cursor) (Page, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return Page{Limit: c.size()}, nil
+	}
+
+	conds := []string{"hashed_password IS NULL"}
+	var args []any
+
+	for _, word := range words {
+		pattern := "%" + escapeLike(word) + "%"
+		conds = append(conds, `(title LIKE ? ESCAPE '\' OR content LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	return m.page(strings.Join(conds, " AND "), args, c)
+}
+
+// The escapeLike() function escapes the characters which have a special
+// meaning in a LIKE pattern, so that they only match themselves.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (m *SQLiteSnippetModel) ForTag(tag string, c Cursor) (Page, error) {
+	filter := `id IN (SELECT st.snippet_id FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+
+	return m.page(filter, []any{tag}, c)
+}
+
+// The page() helper is the SQLite version of SnippetModel.page().
+func (m *SQLiteSnippetModel) page(filter string, filterArgs []any, c Cursor) (Page, error) {
+	limit := c.size()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > ? AND deleted_at IS NULL AND visibility = 'public' AND views_left IS NULL`
+
+	args := append([]any{sqliteNow()}, filterArgs...)
+
+	if filter != "" {
+		stmt += ` AND ` + filter
+	}
+
+	switch {
+	case c.After > 0:
+		stmt += ` AND id > ? ORDER BY id ASC LIMIT ?`
+		args = append(args, c.After, limit+1)
+	case c.Before > 0:
+		stmt += ` AND id < ? ORDER BY id DESC LIMIT ?`
+		args = append(args, c.Before, limit+1)
+	default:
+		stmt += ` ORDER BY id DESC LIMIT ?`
+		args = append(args, limit+1)
+	}
+
+	snippets, err := querySnippets(m.DB, stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return newPage(snippets, limit, c), nil
+}
+
+func (m *SQLiteSnippetModel) TagCounts(limit int) ([]TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	INNER JOIN snippet_tags st ON st.tag_id = t.id
+	INNER JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > ? AND s.deleted_at IS NULL AND s.visibility = 'public' AND s.views_left IS NULL
+	GROUP BY t.name ORDER BY COUNT(*) DESC, t.name LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, sqliteNow(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TagCount
+
+	for rows.Next() {
+		var tc TagCount
+		err = rows.Scan(&tc.Name, &tc.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, tc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (m *SQLiteSnippetModel) ForUser(userID int) ([]Snippet, error) {
+	return (&SnippetModel{DB: m.DB}).ForUser(userID)
+}
+
+func (m *SQLiteSnippetModel) All(limit int) ([]Snippet, error) {
+	return (&SnippetModel{DB: m.DB}).All(limit)
+}
+
+func (m *SQLiteSnippetModel) Forks(id int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE parent_id = ? AND expires > ? AND deleted_at IS NULL ORDER BY id DESC`
+
+	return querySnippets(m.DB, stmt, id, sqliteNow())
+}
+
+func (m *SQLiteSnippetModel) Update(id int, p SnippetParams) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := sqliteNow()
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?,
+	expires = ? WHERE id = ? AND expires > ? AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, p.Title, p.Content, p.Language, p.Visibility, now.AddDate(0, 0, p.Expires), id, now)
+	if err != nil {
+		return err
+	}
+
+	// SQLite counts every row the WHERE clause matched, whether or not it
+	// changed, so no rows means no live snippet.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	err = sqliteInsertRevision(tx, id, p.Title, p.Content, now)
+	if err != nil {
+		return err
+	}
+
+	err = sqliteSetTags(tx, id, p.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *SQLiteSnippetModel) Delete(id int) error {
+	stmt := `UPDATE snippets SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, sqliteNow(), id)
+	return err
+}
+
+// SQLite can't do date arithmetic on the times we store, so we read the
+// expiry and work out the new one in Go. The transaction holds the write lock
+// from the start (see OpenSQLite()), so nothing can change the snippet in
+// between.
+func (m *SQLiteSnippetModel) Extend(id, days int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var expires time.Time
+
+	err = tx.QueryRow("SELECT expires FROM snippets WHERE id = ? AND deleted_at IS NULL", id).Scan(&expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	from := sqliteNow()
+	if expires.After(from) {
+		from = expires.UTC()
+	}
+
+	_, err = tx.Exec("UPDATE snippets SET expires = ? WHERE id = ?", from.AddDate(0, 0, days), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *SQLiteSnippetModel) Remove(id int) error {
+	return (&SnippetModel{DB: m.DB}).Remove(id)
+}
+
+func (m *SQLiteSnippetModel) Trash(userID int, retention time.Duration) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND deleted_at > ? ORDER BY deleted_at DESC`
+
+	return querySnippets(m.DB, stmt, userID, sqliteNow().Add(-retention))
+}
+
+func (m *SQLiteSnippetModel) Restore(userID, id int, retention time.Duration) error {
+	stmt := `UPDATE snippets SET deleted_at = NULL
+	WHERE id = ? AND user_id = ? AND deleted_at > ?`
+
+	result, err := m.DB.Exec(stmt, id, userID, sqliteNow().Add(-retention))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *SQLiteSnippetModel) Purge(retention time.Duration) (int, error) {
+	result, err := m.DB.Exec("DELETE FROM snippets WHERE deleted_at <= ?", sqliteNow().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// SQLite only supports DELETE ... LIMIT when it's compiled with a special
+// option, so we pick out the batch of IDs with a subquery instead.
+func (m *SQLiteSnippetModel) PurgeExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires <= ? LIMIT ?
+	)`
+
+	result, err := m.DB.Exec(stmt, sqliteNow(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+func (m *SQLiteSnippetModel) Revisions(snippetID int) ([]Revision, error) {
+	return (&SnippetModel{DB: m.DB}).Revisions(snippetID)
+}
+
+func (m *SQLiteSnippetModel) Revision(snippetID, version int) (Revision, error) {
+	return (&SnippetModel{DB: m.DB}).Revision(snippetID, version)
+}
+
+// The sqliteInsertRevision() function is the SQLite version of
+// insertRevision().
+func sqliteInsertRevision(tx *sql.Tx, snippetID int, title, content string, created time.Time) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, title, content, created)
+	SELECT ?, COALESCE(MAX(version), 0) + 1, ?, ?, ?
+	FROM snippet_revisions WHERE snippet_id = ?`
+
+	_, err := tx.Exec(stmt, snippetID, title, content, created, snippetID)
+	return err
+}
+
+// The sqliteSetTags() function is the SQLite version of setTags(). SQLite
+// has no ON DUPLICATE KEY UPDATE, so we insert each tag if it's new and then
+// look up its ID.
+func sqliteSetTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = ?", snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		_, err = tx.Exec("INSERT INTO tags (name) VALUES(?) ON CONFLICT (name) DO NOTHING", name)
+		if err != nil {
+			return err
+		}
+
+		var tagID int
+
+		err = tx.QueryRow("SELECT id FROM tags WHERE name = ?", name).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)", snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Define a SQLiteTokenModel type which implements TokenModelInterface on top
+// of a SQLite database.
+type SQLiteTokenModel struct {
+	DB *sql.DB
+}
+
+func (m *SQLiteTokenModel) Insert(userID int, name string, scopes []string, expires int) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := sqliteNow()
+
+	// As in the MySQL model, an expires value of 0 means the token never
+	// expires, which we store as NULL.
+	var expiresAt sql.NullTime
+	if expires > 0 {
+		expiresAt = sql.NullTime{Time: now.AddDate(0, 0, expires), Valid: true}
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scopes, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash, strings.Join(scopes, ","), now, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+func (m *SQLiteTokenModel) GetForToken(plaintext string) (Token, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+	now := sqliteNow()
+
+	stmt := `SELECT id, user_id, name, hash, scopes, created, expires, last_used FROM tokens
+	WHERE hash = ? AND (expires IS NULL OR expires > ?)
+	AND user_id IN (SELECT id FROM users WHERE NOT disabled)`
+
+	t, err := scanToken(m.DB.QueryRow(stmt, hash[:], now))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, ErrNoRecord
+		}
+		return Token{}, err
+	}
+
+	_, err = m.DB.Exec("UPDATE tokens SET last_used = ? WHERE id = ?", now, t.ID)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return t, nil
+}
+
+func (m *SQLiteTokenModel) ForUser(userID int) ([]Token, error) {
+	return (&TokenModel{DB: m.DB}).ForUser(userID)
+}
+
+func (m *SQLiteTokenModel) Revoke(userID, id int) error {
+	return (&TokenModel{DB: m.DB}).Revoke(userID, id)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a SQLiteUserModel type which implements UserModelInterface on top of
+// a SQLite database opened with OpenSQLite().
+type SQLiteUserModel struct {
+	DB *sql.DB
+}
+
+func (m *SQLiteUserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES(?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword), sqliteNow())
+	if err != nil {
+		if isSQLiteUniqueViolation(err, "users.email") {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return nil
+}
+
+// The rest of the user queries don't use anything specific to MySQL, so we
+// just run the MySQL model's versions of them against the SQLite database.
+
+func (m *SQLiteUserModel) Authenticate(email, password string) (int, error) {
+	return (&UserModel{DB: m.DB}).Authenticate(email, password)
+}
+
+func (m *SQLiteUserModel) Exists(id int) (bool, error) {
+	return (&UserModel{DB: m.DB}).Exists(id)
+}
+
+func (m *SQLiteUserModel) Get(id int) (User, error) {
+	return (&UserModel{DB: m.DB}).Get(id)
+}
+
+func (m *SQLiteUserModel) GetByEmail(email string) (User, error) {
+	return (&UserModel{DB: m.DB}).GetByEmail(email)
+}
+
+func (m *SQLiteUserModel) List() ([]User, error) {
+	return listUsers(m.DB)
+}
+
+func (m *SQLiteUserModel) SetPassword(id int, password string) error {
+	return (&UserModel{DB: m.DB}).SetPassword(id, password)
+}
+
+func (m *SQLiteUserModel) SetDisabled(id int, disabled bool) error {
+	return (&UserModel{DB: m.DB}).SetDisabled(id, disabled)
+}
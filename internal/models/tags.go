@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Define a TagCount type to hold the number of live snippets carrying a tag.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// The setTags() function replaces the tags on a snippet. It runs inside the
+// transaction which creates or updates the snippet. Tags are shared between
+// snippets, so each one is only inserted into the tags table if it isn't
+// already there; the ON DUPLICATE KEY UPDATE clause makes LastInsertId()
+// return the ID of the existing row when it is.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = ?", snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		result, err := tx.Exec("INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)", name)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)", snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The attachTags() function fills in the Tags field of each snippet in the
+// slice, using a single query for all of them. Snippets without any tags get
+// an empty slice, so that they're sent as [] rather than null by the API.
+func attachTags(db *sql.DB, snippets []Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	args := make([]any, len(snippets))
+	for i, s := range snippets {
+		args[i] = s.ID
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id IN (?` + strings.Repeat(", ?", len(snippets)-1) + `)
+	ORDER BY t.name`
+
+	return fillTags(db, snippets, stmt, args...)
+}
+
+// The fillTags() function does the work for attachTags(). It runs a query
+// which returns the snippet ID and tag name for each of the snippets' tags,
+// in name order. Only the placeholders differ between the SQL backends, so
+// each of them builds its own query and shares the rest.
+func fillTags(db *sql.DB, snippets []Snippet, stmt string, args ...any) error {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string)
+
+	for rows.Next() {
+		var snippetID int
+		var name string
+
+		err = rows.Scan(&snippetID, &name)
+		if err != nil {
+			return err
+		}
+		tags[snippetID] = append(tags[snippetID], name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range snippets {
+		snippets[i].Tags = tags[snippets[i].ID]
+		if snippets[i].Tags == nil {
+			snippets[i].Tags = []string{}
+		}
+	}
+
+	return nil
+}
+
+// This will return a page of the live, public snippets with a given tag,
+// newest first.
+func (m *SnippetModel) ForTag(tag string, c Cursor) (Page, error) {
+	filter := `id IN (SELECT st.snippet_id FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+
+	return m.page(filter, []any{tag}, c)
+}
+
+// This will return the most used tags and the number of live, public
+// snippets carrying each of them, most used first. Tags which are only on
+// expired, deleted, non-public or view-limited snippets are left out, just as
+// those snippets are left out of ForTag().
+func (m *SnippetModel) TagCounts(limit int) ([]TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	INNER JOIN snippet_tags st ON st.tag_id = t.id
+	INNER JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public' AND s.views_left IS NULL
+	GROUP BY t.name ORDER BY COUNT(*) DESC, t.name LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TagCount
+
+	for rows.Next() {
+		var tc TagCount
+		err = rows.Scan(&tc.Name, &tc.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, tc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Define the scopes that a personal access token can be granted. A token can
+// only be used for the actions covered by its scopes.
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+)
+
+// Scopes lists every scope a token can be granted, in display order.
+var Scopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite}
+
+type TokenModelInterface interface {
+	Insert(userID int, name string, scopes []string, expires int) (string, error)
+	GetForToken(plaintext string) (Token, error)
+	ForUser(userID int) ([]Token, error)
+	Revoke(userID, id int) error
+}
+
+// Define a Token type to hold the data for an individual personal access
+// token. Note that we never store the plain-text token itself, only its
+// SHA-256 hash, so there's no way to recover a token after it's been created.
+// The Expires and LastUsed fields hold the zero time if the token never
+// expires or has never been used.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Hash     []byte
+	Scopes   []string
+	Created  time.Time
+	Expires  time.Time
+	LastUsed time.Time
+}
+
+// HasScope() returns true if the token has been granted the given scope.
+func (t Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Define a TokenModel type which wraps a sql.DB connection pool.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// The Insert() method creates a new token for a user and returns its
+// plain-text value. This is the only time the plain-text value is available,
+// so the caller needs to show it to the user straight away. An expires value
+// of 0 means the token never expires, otherwise it's the lifetime in days.
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expires int) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scopes, created, expires)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), IF(? > 0, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), NULL))`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash, strings.Join(scopes, ","), expires, expires)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// The GetForToken() method looks up an unexpired token by its plain-text
+// value, recording that it has just been used. If there's no such token, or
+// its owner's account has been disabled, we return ErrNoRecord.
+func (m *TokenModel) GetForToken(plaintext string) (Token, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	stmt := `SELECT id, user_id, name, hash, scopes, created, expires, last_used FROM tokens
+	WHERE hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())
+	AND user_id IN (SELECT id FROM users WHERE NOT disabled)`
+
+	t, err := scanToken(m.DB.QueryRow(stmt, hash[:]))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, ErrNoRecord
+		}
+		return Token{}, err
+	}
+
+	_, err = m.DB.Exec("UPDATE tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?", t.ID)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return t, nil
+}
+
+// The ForUser() method returns all of a user's tokens, including expired
+// ones, with the most recently created first.
+func (m *TokenModel) ForUser(userID int) ([]Token, error) {
+	stmt := `SELECT id, user_id, name, hash, scopes, created, expires, last_used FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []Token
+
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// The Revoke() method deletes a token. The user ID is part of the WHERE
+// clause so that users can only ever revoke their own tokens; if nothing
+// matches we return ErrNoRecord.
+func (m *TokenModel) Revoke(userID, id int) error {
+	result, err := m.DB.Exec("DELETE FROM tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// The scanner interface is satisfied by both *sql.Row and *sql.Rows, which
+// lets scanToken() be shared by single-row and multi-row queries.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(s scanner) (Token, error) {
+	var t Token
+	var scopes string
+	var expires, lastUsed sql.NullTime
+
+	err := s.Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &scopes, &t.Created, &expires, &lastUsed)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	t.Expires = expires.Time
+	t.LastUsed = lastUsed.Time
+
+	return t, nil
+}
+
+// The generateToken() function creates a new random plain-text token along
+// with the SHA-256 hash that gets stored in the database. The token is 16
+// bytes from the operating system's CSPRNG, base32-encoded so that it's safe
+// to paste into a terminal.
+func generateToken() (string, []byte, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	hash := sha256.Sum256([]byte(plaintext))
+
+	return plaintext, hash[:], nil
+}
@@ -14,16 +14,28 @@ type UserModelInterface interface {
 	Insert(name, email, password string) error
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
+	Get(id int) (User, error)
+	GetByEmail(email string) (User, error)
+	List() ([]User, error)
+	SetPassword(id int, password string) error
+	SetDisabled(id int, disabled bool) error
 }
 
 // Define a new User struct. NOtice how the field names and types align
 // with the columns in the database "users" table?
+//
+// The struct tags control how a user is represented in the admin tool's JSON
+// output. The hashed password is never included.
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	HashedPassword []byte    `json:"-"`
+	Created        time.Time `json:"created"`
+	// Disabled is true if an administrator has disabled the account. A
+	// disabled user can't log in, and Exists() reports them as not existing,
+	// so any sessions they already have stop working too.
+	Disabled bool `json:"disabled"`
 }
 
 // Define a new UserModel struct which wraps a database connection pool.
@@ -71,10 +83,11 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	// no matching eamil exists we return the ErrInvalidCredetials error.
 	var id int
 	var hashedPassword []byte
+	var disabled bool
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+	stmt := "SELECT id, hashed_password, disabled FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -94,16 +107,149 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		}
 	}
 
+	// The password is correct, but a disabled user still isn't allowed in.
+	// We only check this once the password has been verified, so that the
+	// error doesn't tell anyone else that the account exists.
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
 	// Otherwise, the password is correct. Return the user ID.
 	return id, nil
 }
 
+// Disabled users are treated as if they don't exist, which is what logs them
+// out of any sessions they already have.
 func (m *UserModel) Exists(id int) (bool, error) {
 	var exists bool
 
-	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND NOT disabled)"
 
 	err := m.DB.QueryRow(stmt, id).Scan(&exists)
 
 	return exists, err
 }
+
+// The columns selected by every user query, in the order that scanUser()
+// expects them. The hashed password is deliberately left out, as nothing that
+// displays a user's details should ever need it.
+const userColumns = "id, name, email, created, disabled"
+
+// The scanUser() helper copies a row selected with userColumns into a User
+// struct.
+func scanUser(row scanner) (User, error) {
+	var user User
+
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Disabled)
+
+	return user, err
+}
+
+// The Get() method returns the details of a specific user, whether or not
+// their account has been disabled.
+func (m *UserModel) Get(id int) (User, error) {
+	return getUser(m.DB, "SELECT "+userColumns+" FROM users WHERE id = ?", id)
+}
+
+// The GetByEmail() method returns the details of the user with the given
+// email address.
+func (m *UserModel) GetByEmail(email string) (User, error) {
+	return getUser(m.DB, "SELECT "+userColumns+" FROM users WHERE email = ?", email)
+}
+
+// The getUser() function runs a query for a single user, returning
+// ErrNoRecord if there isn't one. It's shared with the other SQL backends.
+func getUser(db *sql.DB, stmt string, arg any) (User, error) {
+	user, err := scanUser(db.QueryRow(stmt, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		} else {
+			return User{}, err
+		}
+	}
+
+	return user, nil
+}
+
+// The List() method returns every user, oldest first.
+func (m *UserModel) List() ([]User, error) {
+	return listUsers(m.DB)
+}
+
+// The listUsers() function does the work for List(). The query is the same
+// for every SQL backend.
+func listUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query("SELECT " + userColumns + " FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// The SetPassword() method replaces a user's password. If there's no user
+// with the given ID we return ErrNoRecord.
+func (m *UserModel) SetPassword(id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	return m.update(id, "UPDATE users SET hashed_password = ? WHERE id = ?", string(hashedPassword), id)
+}
+
+// The SetDisabled() method disables or re-enables a user's account. If
+// there's no user with the given ID we return ErrNoRecord.
+func (m *UserModel) SetDisabled(id int, disabled bool) error {
+	return m.update(id, "UPDATE users SET disabled = ? WHERE id = ?", disabled, id)
+}
+
+// The update() helper runs an UPDATE statement on a user's row, returning
+// ErrNoRecord if the user doesn't exist. MySQL's RowsAffected() only counts
+// rows which actually changed, so disabling a user who's already disabled
+// affects no rows; when that happens we check whether the user is there at
+// all.
+func (m *UserModel) update(id int, stmt string, args ...any) error {
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+
+	err = m.DB.QueryRow("SELECT EXISTS(SELECT true FROM users WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
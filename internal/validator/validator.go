@@ -14,6 +14,11 @@ import (
 // variable is more performant than re-parsing the pattern each time we need it.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-z0-9-]{0,61}[a-zA-z0-9])?(?:\\.[a-zA-])")
 
+// TagRX matches a valid snippet tag: up to 32 lowercase letters, digits and
+// the characters + . _ - starting with a letter or digit. Tags appear in
+// URLs, so we keep them to characters which don't need escaping.
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+._-]{0,31}$`)
+
 // Define a new Validator struct which contains a map of validation error messages
 // for our form fields.
 type Validator struct {
@@ -80,3 +85,19 @@ func MinChars(value string, n int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// MaxItems() returns true if a slice contains no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+// AllMatch() returns true if every value in a slice matches a provided
+// compiled regular expression pattern.
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for _, value := range values {
+		if !rx.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}